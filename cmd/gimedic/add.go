@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+)
+
+var addCommand = &cobra.Command{
+	Use:   "add <key> <value>",
+	Short: "Add an entry to a dictionary",
+	Long: "Add an entry to a dictionary, creating the dictionary when it does not exist.\n" +
+		"The previous file is kept with a .bak suffix.",
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := resolvePath(cmd, nil)
+		if err != nil {
+			return err
+		}
+		dictName, err := cmd.Flags().GetString("dict")
+		if err != nil {
+			return err
+		}
+		record, err := recordFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		record.Key, record.Value = args[0], args[1]
+		storage, err := syncer.LoadStorage(path)
+		if err != nil {
+			return err
+		}
+		dict := syncer.EnsureDictionary(storage, dictName)
+		entry := record.Entry()
+		for _, existing := range dict.GetEntries() {
+			if entryKey(existing) == entryKey(entry) {
+				return fmt.Errorf("[%s] %s already exists; use set to modify it", dict.GetName(), formatEntry(existing))
+			}
+		}
+		dict.Entries = append(dict.Entries, entry)
+		if err := writeBack(path, storage); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "ADD [%s] %s\n", dict.GetName(), formatEntry(entry))
+		return nil
+	},
+}
+
+func init() {
+	addCommand.Flags().String("path", "", "Path to user_dictionary.db (overrides auto-detect)")
+	addCommand.Flags().String("dict", "", `Dictionary name (default: "default")`)
+	addEntryFlags(addCommand)
+	facadeCommand.AddCommand(addCommand)
+}
+
+// addEntryFlags registers the flags for the fields of an entry other
+// than the key and value.
+func addEntryFlags(cmd *cobra.Command) {
+	cmd.Flags().String("pos", gimedic.PartNoun.String(), "POS label or number")
+	cmd.Flags().String("comment", "", "Comment")
+	cmd.Flags().String("locale", "", "Locale")
+}
+
+// recordFromFlags reads the flags registered by addEntryFlags.
+func recordFromFlags(cmd *cobra.Command) (gimedic.Record, error) {
+	var record gimedic.Record
+	posLabel, err := cmd.Flags().GetString("pos")
+	if err != nil {
+		return record, err
+	}
+	if record.Pos, err = parsePartFlag(posLabel); err != nil {
+		return record, err
+	}
+	if record.Comment, err = cmd.Flags().GetString("comment"); err != nil {
+		return record, err
+	}
+	if record.Locale, err = cmd.Flags().GetString("locale"); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// entryMatch is an entry found by matchingEntries.
+type entryMatch struct {
+	dict  *gimedic.UserDictionary
+	entry *gimedic.UserDictionary_Entry
+}
+
+// matchingEntries returns the entries with the key, and the value unless
+// it is empty, in the dictionary named dictName or in every dictionary
+// when dictName is empty.
+func matchingEntries(storage *gimedic.UserDictionaryStorage, dictName, key, value string) []entryMatch {
+	var matches []entryMatch
+	for _, dict := range storage.GetDictionaries() {
+		if dictName != "" && dict.GetName() != dictName {
+			continue
+		}
+		for _, entry := range dict.GetEntries() {
+			if entry.GetKey() == key && (value == "" || entry.GetValue() == value) {
+				matches = append(matches, entryMatch{dict: dict, entry: entry})
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestEntryCommands(t *testing.T) {
+	path := writeTestDB(t, testDictionary("main", gimedic.Record{Key: "あい", Value: "愛", Pos: gimedic.PartNoun}))
+	steps := []struct {
+		args    []string
+		wantOut string
+		wantErr string
+		want    []string
+	}{
+		{
+			args:    []string{"add", "あい", "藍", "--dict", "main", "--comment", "color"},
+			wantOut: "ADD [main] あい\t藍\t名詞\tcolor\t\n",
+			want:    []string{"main|あい|愛|名詞|", "main|あい|藍|名詞|color"},
+		},
+		{
+			args:    []string{"add", "あい", "愛", "--dict", "main"},
+			wantErr: "already exists",
+		},
+		{
+			// A homograph differing in POS is another entry.
+			args: []string{"add", "あい", "愛", "--dict", "main", "--pos", "名詞サ変"},
+			want: []string{"main|あい|愛|名詞|", "main|あい|藍|名詞|color", "main|あい|愛|名詞サ変|"},
+		},
+		{
+			args:    []string{"add", "かお", "顔", "--pos", "名刺"},
+			wantErr: "unknown POS",
+		},
+		{
+			args: []string{"add", "かお", "顔", "--dict", "other"},
+			want: []string{"main|あい|愛|名詞|", "main|あい|藍|名詞|color", "main|あい|愛|名詞サ変|", "other|かお|顔|名詞|"},
+		},
+		{
+			args:    []string{"set", "あい", "藍"},
+			wantErr: "nothing to set",
+		},
+		{
+			args:    []string{"set", "あい", "愛", "--pos", "名詞"},
+			wantErr: "would be duplicated",
+		},
+		{
+			args:    []string{"set", "あい", "藍", "--comment", "blue"},
+			wantOut: "UPDATE [main] あい\t藍\t名詞\tcolor\t -> あい\t藍\t名詞\tblue\t\n",
+			want:    []string{"main|あい|愛|名詞|", "main|あい|藍|名詞|blue", "main|あい|愛|名詞サ変|", "other|かお|顔|名詞|"},
+		},
+		{
+			args:    []string{"set", "あい", "藍", "--comment", "blue"},
+			wantOut: "no changes\n",
+		},
+		{
+			args:    []string{"set", "なし", "無し", "--comment", "x"},
+			wantErr: "no entry",
+		},
+		{
+			args:    []string{"rm", "なし"},
+			wantErr: "no entry",
+		},
+		{
+			args: []string{"rm", "あい", "愛"},
+			want: []string{"main|あい|藍|名詞|blue", "other|かお|顔|名詞|"},
+		},
+		{
+			args: []string{"rm", "かお", "--dict", "other"},
+			want: []string{"main|あい|藍|名詞|blue", "other|"},
+		},
+	}
+	for _, step := range steps {
+		before := dumpDB(t, path)
+		out, err := runCommand(t, append(step.args, "--path", path)...)
+		name := strings.Join(step.args, " ")
+		if step.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), step.wantErr) {
+				t.Fatalf("%s: got error %v, want %q", name, err, step.wantErr)
+			}
+			if got := dumpDB(t, path); !reflect.DeepEqual(got, before) {
+				t.Fatalf("%s: failed command changed the file: %v", name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if step.wantOut != "" && out != step.wantOut {
+			t.Fatalf("%s: got output %q, want %q", name, out, step.wantOut)
+		}
+		if step.want != nil {
+			if got := dumpDB(t, path); !reflect.DeepEqual(got, step.want) {
+				t.Fatalf("%s: got %v, want %v", name, got, step.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+)
+
+var applyCommand = &cobra.Command{
+	Use:   "apply <events.jsonl|-> [user_dictionary.db]",
+	Short: "Apply journal events to a dictionary file",
+	Long: "Apply a file of journal events, such as the output of diff --format journal, to a dictionary file.\n" +
+		"Unlike pull, apply keeps no state. Events that are already applied are reported and skipped;\n" +
+		"events that conflict with the dictionary are rejected, and the command exits with status 1.\n" +
+		"Events may be JSON lines or a JSON array. The previous file is kept with a .bak suffix.",
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+		reverse, err := cmd.Flags().GetBool("reverse")
+		if err != nil {
+			return err
+		}
+		raw, err := readInput(args[0])
+		if err != nil {
+			return err
+		}
+		events, err := parseEvents(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		if reverse {
+			if events, err = reverseEvents(events); err != nil {
+				return err
+			}
+		}
+		path, err := resolvePath(cmd, args[1:])
+		if err != nil {
+			return err
+		}
+		storage, err := syncer.LoadStorage(path)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		counts := map[syncer.PatchResult]int{}
+		for _, event := range events {
+			result, reason := syncer.CheckEvent(storage, event)
+			counts[result]++
+			switch result {
+			case syncer.PatchClean:
+				syncer.ApplyEvent(storage, event)
+				fmt.Fprintln(out, formatEvent(event))
+			case syncer.PatchApplied:
+				fmt.Fprintf(out, "SKIPPED %s: %s\n", formatEvent(event), reason)
+			case syncer.PatchConflict:
+				fmt.Fprintf(out, "REJECTED %s: %s\n", formatEvent(event), reason)
+			}
+		}
+		fmt.Fprintf(out, "%d applied, %d already applied, %d rejected\n",
+			counts[syncer.PatchClean], counts[syncer.PatchApplied], counts[syncer.PatchConflict])
+		if dryRun {
+			fmt.Fprintln(out, "dry run: nothing written")
+		} else if counts[syncer.PatchClean] > 0 {
+			if err := writeBack(path, storage); err != nil {
+				return err
+			}
+		}
+		if counts[syncer.PatchConflict] > 0 {
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return statusError{status: 1}
+		}
+		return nil
+	},
+}
+
+func init() {
+	applyCommand.Flags().String("path", "", "Path to user_dictionary.db (overrides auto-detect)")
+	applyCommand.Flags().Bool("dry-run", false, "Report what would be applied without writing anything")
+	applyCommand.Flags().Bool("reverse", false, "Undo the events instead of applying them")
+	facadeCommand.AddCommand(applyCommand)
+}
+
+// parseEvents reads journal events as JSON lines or as a JSON array.
+func parseEvents(raw []byte) ([]syncer.JournalEvent, error) {
+	var events []syncer.JournalEvent
+	if trimmed := bytes.TrimSpace(raw); bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return nil, err
+		}
+		return events, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 || syncer.IsJournalHeader(text) {
+			continue
+		}
+		var event syncer.JournalEvent
+		if err := json.Unmarshal(text, &event); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// reverseEvents returns the events that undo events, last first.
+func reverseEvents(events []syncer.JournalEvent) ([]syncer.JournalEvent, error) {
+	reversed := make([]syncer.JournalEvent, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		event, err := syncer.ReverseEvent(events[i])
+		if err != nil {
+			return nil, err
+		}
+		reversed = append(reversed, event)
+	}
+	return reversed, nil
+}
+
+func formatEvent(event syncer.JournalEvent) string {
+	return fmt.Sprintf("%s [%s] %s", strings.ToUpper(event.Op), event.Dict, formatEntry(eventEntry(event)))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+)
+
+func TestParseEvents(t *testing.T) {
+	add := syncer.JournalEvent{Op: "add", Dict: "main", Key: "k", Value: "v", Pos: 1}
+	del := syncer.JournalEvent{Op: "delete", Dict: "main", Key: "k", Value: "v", Pos: 1}
+	tests := []struct {
+		name    string
+		raw     string
+		want    []syncer.JournalEvent
+		wantErr string
+	}{
+		{name: "empty", raw: ""},
+		{
+			name: "lines",
+			raw:  `{"op":"add","dict":"main","key":"k","value":"v","pos":1}` + "\n\n" + `{"op":"delete","dict":"main","key":"k","value":"v","pos":1}` + "\n",
+			want: []syncer.JournalEvent{add, del},
+		},
+		{
+			name: "array",
+			raw:  ` [{"op":"add","dict":"main","key":"k","value":"v","pos":1}]`,
+			want: []syncer.JournalEvent{add},
+		},
+		{
+			name: "compacted journal",
+			raw:  `{"gimedic_journal":1,"id":"x"}` + "\n" + `{"op":"add","dict":"main","key":"k","value":"v","pos":1}` + "\n",
+			want: []syncer.JournalEvent{add},
+		},
+		{
+			name:    "malformed line",
+			raw:     `{"op":"add","dict":"main","key":"k","value":"v","pos":1}` + "\n" + `{"op":` + "\n",
+			wantErr: "line 2",
+		},
+	}
+	for _, test := range tests {
+		got, err := parseEvents([]byte(test.raw))
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestApplyCommand(t *testing.T) {
+	path := writeTestDB(t, testDictionary("main", gimedic.Record{Key: "あ", Value: "亜", Pos: gimedic.PartNoun}))
+	events := t.TempDir() + "/events.jsonl"
+	write := func(lines ...string) {
+		t.Helper()
+		if err := os.WriteFile(events, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			t.Fatalf("write events: %v", err)
+		}
+	}
+
+	write(
+		`{"op":"add","dict":"main","key":"い","value":"井","pos":1}`,
+		`{"op":"add","dict":"main","key":"あ","value":"亜","pos":1}`,
+	)
+	out, err := runCommand(t, "apply", events, "--path", path, "--dry-run")
+	if err != nil || !strings.Contains(out, "1 applied, 1 already applied, 0 rejected") {
+		t.Fatalf("dry run: %q, %v", out, err)
+	}
+	if got := dumpDB(t, path); len(got) != 1 {
+		t.Fatalf("dry run wrote: %v", got)
+	}
+	if _, err := runCommand(t, "apply", events, "--path", path); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got, want := dumpDB(t, path), []string{"main|あ|亜|名詞|", "main|い|井|名詞|"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if _, err := runCommand(t, "apply", events, "--path", path, "--reverse"); err != nil {
+		t.Fatalf("apply --reverse: %v", err)
+	}
+	// Reversing undoes every event, including the one already applied.
+	if got, want := dumpDB(t, path), []string{"main|"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after reverse got %v, want %v", got, want)
+	}
+
+	// An update of a missing entry is rejected with status 1.
+	write(`{"op":"update","dict":"main","key":"あ","value":"亜","pos":1,"comment":"new","prev":{"key":"あ","value":"亜","pos":1,"comment":"old"}}`)
+	out, err = runCommand(t, "apply", events, "--path", path)
+	var status statusError
+	if !errors.As(err, &status) || status.status != 1 || status.err != nil || !strings.Contains(out, "REJECTED") {
+		t.Fatalf("conflicting apply: %q, %v", out, err)
+	}
+	if got, want := dumpDB(t, path), []string{"main|"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("rejected apply wrote: %v", got)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+)
+
+var conflictsCommand = &cobra.Command{
+	Use:   "conflicts",
+	Short: "Manage conflicts found by pull",
+	Long: "Manage the conflicts pull finds when a remote change hits an entry changed locally since the last sync.\n" +
+		"Pull leaves such changes unapplied; resolving a conflict writes the chosen entries and pushes them as new events.",
+}
+
+var conflictsListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List unresolved conflicts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		conflicts, err := loadConflicts(cmd)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "no conflicts")
+			return nil
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tDICT\tKEY\tVALUE\tREMOTE\tORIGIN\tDETECTED")
+		for _, c := range conflicts {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.ID, c.Dict, c.Remote.Key, c.Remote.Value, c.Remote.Op, c.Remote.Origin, c.Detected)
+		}
+		return w.Flush()
+	},
+}
+
+var conflictsShowCommand = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the versions of the entries in a conflict",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conflicts, err := loadConflicts(cmd)
+		if err != nil {
+			return err
+		}
+		conflict, err := findConflict(conflicts, args[0])
+		if err != nil {
+			return err
+		}
+		writeConflict(cmd.OutOrStdout(), conflict, "")
+		return nil
+	},
+}
+
+var conflictsResolveCommand = &cobra.Command{
+	Use:   "resolve <id>",
+	Short: "Resolve a conflict and push the result",
+	Long: "Resolve a conflict by taking the local or the remote version of its entries, or by editing them in $VISUAL or $EDITOR.\n" +
+		"The result is written to user_dictionary.db and appended to the own journal, so that it wins on every peer.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		take, err := cmd.Flags().GetString("take")
+		if err != nil {
+			return err
+		}
+		service, err := conflictsService(cmd)
+		if err != nil {
+			return err
+		}
+		conflicts, err := syncer.LoadConflicts(service.DBPath)
+		if err != nil {
+			return err
+		}
+		conflict, err := findConflict(conflicts, args[0])
+		if err != nil {
+			return err
+		}
+		var want []syncer.EntryState
+		switch take {
+		case "local":
+			for _, entry := range conflict.Entries {
+				if entry.Local != nil {
+					want = append(want, *entry.Local)
+				}
+			}
+		case "remote":
+			for _, entry := range conflict.Entries {
+				if entry.Remote != nil {
+					want = append(want, *entry.Remote)
+				}
+			}
+		case "edit":
+			if want, err = editConflict(cmd.OutOrStdout(), bufio.NewReader(cmd.InOrStdin()), conflict); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown --take %q (available: local, remote, edit)", take)
+		}
+		wrote, err := service.ResolveConflict(conflict.ID, want)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "RESOLVED %s: pushed %d events\n", conflict.ID, wrote)
+		return nil
+	},
+}
+
+func init() {
+	conflictsCommand.PersistentFlags().String("path", "", "Local user_dictionary.db path (overrides auto-detect)")
+	conflictsCommand.PersistentFlags().String("journal-dir", "", "Directory for journal files (overrides default)")
+	conflictsResolveCommand.Flags().String("take", "", "Version to keep: local, remote or edit")
+	_ = conflictsResolveCommand.MarkFlagRequired("take")
+	conflictsCommand.AddCommand(conflictsListCommand, conflictsShowCommand, conflictsResolveCommand)
+	facadeCommand.AddCommand(conflictsCommand)
+}
+
+func conflictsService(cmd *cobra.Command) (syncer.Service, error) {
+	dbPath, err := resolvePath(cmd, nil)
+	if err != nil {
+		return syncer.Service{}, err
+	}
+	journalDir, err := cmd.Flags().GetString("journal-dir")
+	if err != nil {
+		return syncer.Service{}, err
+	}
+	return syncer.Service{DBPath: dbPath, JournalDir: journalDir}, nil
+}
+
+func loadConflicts(cmd *cobra.Command) ([]syncer.Conflict, error) {
+	dbPath, err := resolvePath(cmd, nil)
+	if err != nil {
+		return nil, err
+	}
+	return syncer.LoadConflicts(dbPath)
+}
+
+// findConflict finds a conflict by its id or a unique prefix of it.
+func findConflict(conflicts []syncer.Conflict, ref string) (syncer.Conflict, error) {
+	var found []syncer.Conflict
+	for _, c := range conflicts {
+		if c.ID == ref {
+			return c, nil
+		}
+		if strings.HasPrefix(c.ID, ref) {
+			found = append(found, c)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return syncer.Conflict{}, fmt.Errorf("conflict %q not found", ref)
+	case 1:
+		return found[0], nil
+	}
+	return syncer.Conflict{}, fmt.Errorf("conflict %q is ambiguous", ref)
+}
+
+// writeConflict writes the conflict with the versions of its entries,
+// starting each line with prefix.
+func writeConflict(out io.Writer, c syncer.Conflict, prefix string) {
+	fmt.Fprintf(out, "%sconflict %s in [%s] from %s by %s, detected %s\n", prefix, c.ID, c.Dict, filepath.Base(c.Journal), c.Remote.Origin, c.Detected)
+	fmt.Fprintf(out, "%sremote %s\n", prefix, strings.ToUpper(c.Remote.Op))
+	version := func(state *syncer.EntryState) string {
+		if state == nil {
+			return "(absent)"
+		}
+		return formatEntry(entryStateEntry(*state))
+	}
+	for _, entry := range c.Entries {
+		fmt.Fprintf(out, "%s  base:   %s\n", prefix, version(entry.Base))
+		fmt.Fprintf(out, "%s  local:  %s\n", prefix, version(entry.Local))
+		fmt.Fprintf(out, "%s  remote: %s\n", prefix, version(entry.Remote))
+	}
+}
+
+// editConflict lets the user edit the remote version of the entries, or
+// the local one when the remote change deletes them.
+func editConflict(out io.Writer, in *bufio.Reader, c syncer.Conflict) ([]syncer.EntryState, error) {
+	proposal := &gimedic.UserDictionary{Name: &c.Dict}
+	for _, entry := range c.Entries {
+		if entry.Remote != nil {
+			proposal.Entries = append(proposal.Entries, entryStateEntry(*entry.Remote))
+		}
+	}
+	if len(proposal.Entries) == 0 {
+		for _, entry := range c.Entries {
+			if entry.Local != nil {
+				proposal.Entries = append(proposal.Entries, entryStateEntry(*entry.Local))
+			}
+		}
+	}
+
+	file, err := os.CreateTemp("", "gimedic-conflict-*.tsv")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	var buf bytes.Buffer
+	buf.WriteString("# Edit the entries that settle the conflict; lines starting with # are ignored.\n" +
+		"# Removing every row deletes the entries.\n")
+	writeConflict(&buf, c, "# ")
+	if err := gimedic.WriteTSV(&buf, &gimedic.UserDictionaryStorage{Dictionaries: []*gimedic.UserDictionary{proposal}}); err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	edited, err := editUntilValid(out, in, file.Name())
+	if err != nil {
+		return nil, err
+	}
+	var want []syncer.EntryState
+	for _, record := range gimedic.Records(edited) {
+		want = append(want, syncer.EntryState{
+			Key:     record.Key,
+			Value:   record.Value,
+			Comment: record.Comment,
+			Locale:  record.Locale,
+			Pos:     int32(record.Pos),
+		})
+	}
+	return want, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+)
+
+// setupConflict makes a dictionary whose entry あ was commented "local"
+// since the last push while another peer commented it "remote", and pulls
+// the remote change into a conflict. It returns the dictionary path, the
+// journal directory and the conflict id.
+func setupConflict(t *testing.T) (string, string, string) {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := writeTestDB(t, testDictionary("main", gimedic.Record{Key: "あ", Value: "亜", Pos: gimedic.PartNoun}))
+	journalDir := t.TempDir()
+	service := syncer.Service{DBPath: path, JournalDir: journalDir, Origin: "peer-a"}
+	own, err := service.OwnJournalPath()
+	if err != nil {
+		t.Fatalf("OwnJournalPath: %v", err)
+	}
+	if _, err := service.Push(own); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	storage, err := syncer.LoadStorage(path)
+	if err != nil {
+		t.Fatalf("LoadStorage: %v", err)
+	}
+	comment := "local"
+	storage.GetDictionaries()[0].GetEntries()[0].Comment = &comment
+	if err := syncer.WriteStorage(path, storage); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	other := journalDir + "/other.jsonl"
+	line := `{"op":"update","dict":"main","key":"あ","value":"亜","pos":1,"comment":"remote","hlc":{"wall":9999999999999,"logical":0},"origin":"peer-b"}` + "\n"
+	if err := os.WriteFile(other, []byte(line), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	if _, err := service.Pull([]string{other}); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	conflicts, err := syncer.LoadConflicts(path)
+	if err != nil || len(conflicts) != 1 {
+		t.Fatalf("LoadConflicts: %v, %v", conflicts, err)
+	}
+	return path, journalDir, conflicts[0].ID
+}
+
+func TestConflictsList(t *testing.T) {
+	path, _, id := setupConflict(t)
+	out, err := runCommand(t, "conflicts", "list", "--path", path)
+	if err != nil {
+		t.Fatalf("conflicts list: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") {
+		t.Fatalf("unexpected list:\n%s", out)
+	}
+	if fields := strings.Fields(lines[1]); len(fields) < 6 || fields[0] != id || fields[1] != "main" || fields[2] != "あ" || fields[4] != "update" || fields[5] != "peer-b" {
+		t.Fatalf("unexpected row: %q", lines[1])
+	}
+
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if out, err := runCommand(t, "conflicts", "list", "--path", path); err != nil || out != "no conflicts\n" {
+		t.Fatalf("list without conflicts: %q, %v", out, err)
+	}
+}
+
+func TestConflictsResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		take    string
+		script  string
+		want    []string
+		wantErr string
+	}{
+		{name: "local", take: "local", want: []string{"main|あ|亜|名詞|local"}},
+		{name: "remote", take: "remote", want: []string{"main|あ|亜|名詞|remote"}},
+		{name: "edit", take: "edit", script: `sed -i 's/remote/edited/' "$1"`, want: []string{"main|あ|亜|名詞|edited"}},
+		{name: "edit to nothing", take: "edit", script: `sed -i '/^[^#].*亜/d' "$1"`, want: []string{"main|"}},
+		{name: "unknown", take: "newest", wantErr: `unknown --take "newest"`, want: []string{"main|あ|亜|名詞|local"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.script != "" {
+				if _, err := exec.LookPath("sh"); err != nil {
+					t.Skip("no sh to act as the editor")
+				}
+				script := t.TempDir() + "/editor.sh"
+				if err := os.WriteFile(script, []byte(test.script+"\n"), 0o600); err != nil {
+					t.Fatalf("write script: %v", err)
+				}
+				t.Setenv("VISUAL", "sh "+script)
+			}
+			path, journalDir, id := setupConflict(t)
+			// A prefix of the id is enough.
+			out, err := runCommand(t, "conflicts", "resolve", id[:8], "--take", test.take, "--path", path, "--journal-dir", journalDir)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("conflicts resolve: %v", err)
+				}
+				if !strings.HasPrefix(out, "RESOLVED "+id+": pushed ") {
+					t.Fatalf("unexpected output: %q", out)
+				}
+				if conflicts, _ := syncer.LoadConflicts(path); len(conflicts) != 0 {
+					t.Fatalf("conflict left: %v", conflicts)
+				}
+			}
+			if got := dumpDB(t, path); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConflictsResolveNotFound(t *testing.T) {
+	path, journalDir, _ := setupConflict(t)
+	_, err := runCommand(t, "conflicts", "resolve", "nope", "--take", "local", "--path", path, "--journal-dir", journalDir)
+	if err == nil || !strings.Contains(err.Error(), `conflict "nope" not found`) {
+		t.Fatalf("got error %v, want the conflict not found", err)
+	}
+}
@@ -4,28 +4,67 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 
+	"github.com/apex/log"
 	"github.com/kyoh86/gimedic"
 	"github.com/spf13/cobra"
 	"google.golang.org/protobuf/proto"
 )
 
+var decodeFormats = map[string]func(io.Writer, *gimedic.UserDictionaryStorage) error{
+	"text":   writeText,
+	"json":   gimedic.WriteJSON,
+	"ndjson": gimedic.WriteNDJSON,
+	"csv":    gimedic.WriteCSV,
+	"tsv":    gimedic.WriteTSV,
+	"yaml":   gimedic.WriteYAML,
+	"mozc":   entryWriter(gimedic.WriteMozcTSV),
+	"msime":  lossyEntryWriter(gimedic.WriteMSIME),
+	"atok":   lossyEntryWriter(gimedic.WriteATOK),
+	"skk": lossyEntryWriter(func(w io.Writer, entries []*gimedic.UserDictionary_Entry) ([]gimedic.Skipped, error) {
+		return gimedic.WriteSKK(w, entries, gimedic.SKKUTF8)
+	}),
+	"skk-euc": lossyEntryWriter(func(w io.Writer, entries []*gimedic.UserDictionary_Entry) ([]gimedic.Skipped, error) {
+		return gimedic.WriteSKK(w, entries, gimedic.SKKEUCJP)
+	}),
+	"gboard": entryWriter(gimedic.WriteGboard),
+	"plist":  entryWriter(gimedic.WritePlist),
+
+	"mecab-ipadic": lossyEntryWriter(gimedic.WriteMeCabIPADIC),
+	"mecab-unidic": lossyEntryWriter(gimedic.WriteMeCabUniDic),
+	"sudachi":      lossyEntryWriter(gimedic.WriteSudachi),
+	"kuromoji":     lossyEntryWriter(gimedic.WriteKuromoji),
+
+	"textproto": gimedic.WriteTextproto,
+}
+
 var decodeCommand = &cobra.Command{
-	Use:   "decode",
+	Use:   "decode [user_dictionary.db|-]",
 	Short: "Decode a dictionary to human-readable",
-	Args:  cobra.RangeArgs(0, 1),
+	Long: "Decode a dictionary to human-readable or machine-readable text.\n" +
+		"Pass - to read the dictionary from stdin.\n\n" +
+		"The mecab-ipadic, mecab-unidic and kuromoji formats write verbs and\n" +
+		"adjectives as a single row in their base form, without rows for the\n" +
+		"inflected forms. The sudachi format only writes common and proper\n" +
+		"nouns, the parts of speech whose connection ids are known; other\n" +
+		"entries, verbs and adjectives included, are reported as skipped.",
+	Args: cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		path, err := resolvePath(cmd, args)
+		format, err := cmd.Flags().GetString("format")
 		if err != nil {
 			return err
 		}
-		file, err := os.Open(path)
+		write, ok := decodeFormats[format]
+		if !ok {
+			return fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(formatNames(decodeFormats), ", "))
+		}
+		path, err := resolvePath(cmd, args)
 		if err != nil {
 			return err
 		}
-		defer file.Close()
-		raw, err := io.ReadAll(file)
+		raw, err := readInput(path)
 		if err != nil {
 			return err
 		}
@@ -33,28 +72,118 @@ var decodeCommand = &cobra.Command{
 		if err := proto.Unmarshal(raw, &storage); err != nil {
 			return err
 		}
-
-		fmt.Printf("version: %d\n", storage.GetVersion())
-		for _, dict := range storage.GetDictionaries() {
-			fmt.Printf("\n[%s] id=%d\n", dict.GetName(), dict.GetId())
-			for _, entry := range dict.GetEntries() {
-				pos := gimedic.Part(entry.GetPos()).String()
-				comment := strings.ReplaceAll(entry.GetComment(), "\n", " ")
-				fmt.Printf("%s\t%s\t%s\t%s\t%s\n",
-					entry.GetKey(),
-					entry.GetValue(),
-					pos,
-					comment,
-					entry.GetLocale(),
-				)
-			}
+		dictNames, err := cmd.Flags().GetStringArray("dict")
+		if err != nil {
+			return err
 		}
-
-		return nil
+		if err := selectDictionaries(&storage, dictNames); err != nil {
+			return err
+		}
+		return write(cmd.OutOrStdout(), &storage)
 	},
 }
 
 func init() {
 	decodeCommand.Flags().String("path", "", "Path to user_dictionary.db (overrides auto-detect)")
+	decodeCommand.Flags().String("format", "text", "Output format ("+strings.Join(formatNames(decodeFormats), ", ")+")")
+	decodeCommand.Flags().StringArray("dict", nil, "Dictionary name to decode (repeatable; default: all)")
 	facadeCommand.AddCommand(decodeCommand)
 }
+
+func writeText(w io.Writer, storage *gimedic.UserDictionaryStorage) error {
+	if _, err := fmt.Fprintf(w, "version: %d\n", storage.GetVersion()); err != nil {
+		return err
+	}
+	for _, dict := range storage.GetDictionaries() {
+		if _, err := fmt.Fprintf(w, "\n[%s] id=%d\n", dict.GetName(), dict.GetId()); err != nil {
+			return err
+		}
+		for _, entry := range dict.GetEntries() {
+			pos := gimedic.Part(entry.GetPos()).String()
+			comment := strings.ReplaceAll(entry.GetComment(), "\n", " ")
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				entry.GetKey(),
+				entry.GetValue(),
+				pos,
+				comment,
+				entry.GetLocale(),
+			); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entryWriter adapts a single-dictionary writer to write the entries of
+// every dictionary in the storage one after another.
+func entryWriter(write func(io.Writer, []*gimedic.UserDictionary_Entry) error) func(io.Writer, *gimedic.UserDictionaryStorage) error {
+	return func(w io.Writer, storage *gimedic.UserDictionaryStorage) error {
+		var entries []*gimedic.UserDictionary_Entry
+		for _, dict := range storage.GetDictionaries() {
+			entries = append(entries, dict.GetEntries()...)
+		}
+		return write(w, entries)
+	}
+}
+
+// lossyEntryWriter is like entryWriter for writers that leave out entries
+// they cannot represent. Those entries are reported as warnings.
+func lossyEntryWriter(write func(io.Writer, []*gimedic.UserDictionary_Entry) ([]gimedic.Skipped, error)) func(io.Writer, *gimedic.UserDictionaryStorage) error {
+	return entryWriter(func(w io.Writer, entries []*gimedic.UserDictionary_Entry) error {
+		skipped, err := write(w, entries)
+		if err != nil {
+			return err
+		}
+		warnSkipped(skipped)
+		return nil
+	})
+}
+
+func warnSkipped(skipped []gimedic.Skipped) {
+	for _, s := range skipped {
+		log.Warnf("skipped %s", s)
+	}
+	if len(skipped) > 0 {
+		log.Warnf("%d entries skipped", len(skipped))
+	}
+}
+
+// selectDictionaries drops every dictionary not named in names.
+// An empty names keeps all dictionaries.
+func selectDictionaries(storage *gimedic.UserDictionaryStorage, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	byName := map[string]*gimedic.UserDictionary{}
+	for _, dict := range storage.GetDictionaries() {
+		byName[dict.GetName()] = dict
+	}
+	selected := make([]*gimedic.UserDictionary, 0, len(names))
+	for _, name := range names {
+		dict, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("dictionary %q not found", name)
+		}
+		selected = append(selected, dict)
+	}
+	storage.Dictionaries = selected
+	return nil
+}
+
+// readInput reads the whole file, or stdin when path is "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func formatNames[T any](formats map[string]T) []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
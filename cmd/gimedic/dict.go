@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+var dictCommand = &cobra.Command{
+	Use:   "dict",
+	Short: "Manage dictionaries",
+	Long: "Manage the dictionaries in user_dictionary.db.\n" +
+		"Dictionaries are referred to by name or by id. Changes keep the previous file with a .bak suffix.",
+}
+
+var dictListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List dictionaries with their entry counts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		path, err := resolvePath(cmd, nil)
+		if err != nil {
+			return err
+		}
+		storage, err := syncer.LoadStorage(path)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tENTRIES")
+		for _, dict := range storage.GetDictionaries() {
+			fmt.Fprintf(w, "%d\t%s\t%d\n", dict.GetId(), dict.GetName(), len(dict.GetEntries()))
+		}
+		return w.Flush()
+	},
+}
+
+var dictCreateCommand = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create an empty dictionary",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editDictionaries(cmd, func(storage *gimedic.UserDictionaryStorage) (string, error) {
+			name := args[0]
+			if err := checkDictionaryName(storage, name); err != nil {
+				return "", err
+			}
+			dict := syncer.EnsureDictionary(storage, name)
+			return fmt.Sprintf("CREATE DICTIONARY [%s] id=%d", name, dict.GetId()), nil
+		})
+	},
+}
+
+var dictRenameCommand = &cobra.Command{
+	Use:   "rename <dict> <new-name>",
+	Short: "Rename a dictionary",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editDictionaries(cmd, func(storage *gimedic.UserDictionaryStorage) (string, error) {
+			dict, err := findDictionary(storage, args[0])
+			if err != nil {
+				return "", err
+			}
+			name := args[1]
+			if err := checkDictionaryName(storage, name); err != nil {
+				return "", err
+			}
+			old := dict.GetName()
+			dict.Name = &name
+			return fmt.Sprintf("RENAME DICTIONARY [%s] -> [%s]", old, name), nil
+		})
+	},
+}
+
+var dictCopyCommand = &cobra.Command{
+	Use:   "copy <dict> <new-name>",
+	Short: "Copy a dictionary with its entries",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editDictionaries(cmd, func(storage *gimedic.UserDictionaryStorage) (string, error) {
+			dict, err := findDictionary(storage, args[0])
+			if err != nil {
+				return "", err
+			}
+			name := args[1]
+			if err := checkDictionaryName(storage, name); err != nil {
+				return "", err
+			}
+			copied := proto.Clone(dict).(*gimedic.UserDictionary)
+			id := syncer.UniqueDictionaryID(storage)
+			copied.Id = &id
+			copied.Name = &name
+			storage.Dictionaries = append(storage.Dictionaries, copied)
+			return fmt.Sprintf("COPY DICTIONARY [%s] -> [%s] id=%d (%d entries)", dict.GetName(), name, id, len(copied.GetEntries())), nil
+		})
+	},
+}
+
+var dictDeleteCommand = &cobra.Command{
+	Use:   "delete <dict>",
+	Short: "Delete a dictionary with its entries",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editDictionaries(cmd, func(storage *gimedic.UserDictionaryStorage) (string, error) {
+			dict, err := findDictionary(storage, args[0])
+			if err != nil {
+				return "", err
+			}
+			kept := storage.GetDictionaries()[:0]
+			for _, d := range storage.GetDictionaries() {
+				if d != dict {
+					kept = append(kept, d)
+				}
+			}
+			storage.Dictionaries = kept
+			return fmt.Sprintf("DELETE DICTIONARY [%s] (%d entries)", dict.GetName(), len(dict.GetEntries())), nil
+		})
+	},
+}
+
+func init() {
+	dictCommand.PersistentFlags().String("path", "", "Path to user_dictionary.db (overrides auto-detect)")
+	dictCommand.AddCommand(dictListCommand, dictCreateCommand, dictRenameCommand, dictCopyCommand, dictDeleteCommand)
+	facadeCommand.AddCommand(dictCommand)
+}
+
+// editDictionaries loads the dictionary file, applies edit, writes it
+// back and prints the change edit describes.
+func editDictionaries(cmd *cobra.Command, edit func(*gimedic.UserDictionaryStorage) (string, error)) error {
+	path, err := resolvePath(cmd, nil)
+	if err != nil {
+		return err
+	}
+	storage, err := syncer.LoadStorage(path)
+	if err != nil {
+		return err
+	}
+	change, err := edit(storage)
+	if err != nil {
+		return err
+	}
+	if err := writeBack(path, storage); err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), change)
+	return nil
+}
+
+// findDictionary finds a dictionary by name, or by id when no dictionary
+// has that name.
+func findDictionary(storage *gimedic.UserDictionaryStorage, ref string) (*gimedic.UserDictionary, error) {
+	for _, dict := range storage.GetDictionaries() {
+		if dict.GetName() == ref {
+			return dict, nil
+		}
+	}
+	if id, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		for _, dict := range storage.GetDictionaries() {
+			if dict.GetId() == id {
+				return dict, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("dictionary %q not found", ref)
+}
+
+// checkDictionaryName refuses empty names and names already in use.
+func checkDictionaryName(storage *gimedic.UserDictionaryStorage, name string) error {
+	if name == "" {
+		return errors.New("dictionary name is empty")
+	}
+	for _, dict := range storage.GetDictionaries() {
+		if dict.GetName() == name {
+			return fmt.Errorf("dictionary %q already exists (id=%d)", name, dict.GetId())
+		}
+	}
+	return nil
+}
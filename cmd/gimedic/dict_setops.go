@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+)
+
+const dictRefHelp = "A dictionary is referred to by name or id in the dictionary file, " +
+	"or as <file>:<dict> to take it from another file (user_dictionary.db or any format encode reads).\n" +
+	"Entries are identified by key, value and POS. The result becomes a new dictionary with a fresh id."
+
+var dictUnionCommand = &cobra.Command{
+	Use:   "union <new-name> <dict> <dict>...",
+	Short: "Create a dictionary with the entries of any of the dictionaries",
+	Long: "Create a dictionary with the entries found in any of the dictionaries.\n" +
+		"An entry in several dictionaries with the same POS takes its comment and locale from the first;\n" +
+		"entries differing in POS are all kept.\n" + dictRefHelp,
+	Args: cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setOperation(cmd, "UNION", args[0], args[1:], func(dicts []*gimedic.UserDictionary) []*gimedic.UserDictionary_Entry {
+			return gimedic.Union(dicts...)
+		})
+	},
+}
+
+var dictIntersectCommand = &cobra.Command{
+	Use:   "intersect <new-name> <dict> <dict>...",
+	Short: "Create a dictionary with the entries common to all the dictionaries",
+	Long: "Create a dictionary with the entries every dictionary holds.\n" +
+		"The entries take their comment and locale from the first dictionary.\n" + dictRefHelp,
+	Args: cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setOperation(cmd, "INTERSECT", args[0], args[1:], func(dicts []*gimedic.UserDictionary) []*gimedic.UserDictionary_Entry {
+			return gimedic.Intersection(dicts[0], dicts[1:]...)
+		})
+	},
+}
+
+var dictSubtractCommand = &cobra.Command{
+	Use:   "subtract <new-name> <dict> <dict>...",
+	Short: "Create a dictionary with the entries of the first dictionary the others lack",
+	Long:  "Create a dictionary with the entries of the first dictionary that none of the others holds.\n" + dictRefHelp,
+	Args:  cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setOperation(cmd, "SUBTRACT", args[0], args[1:], func(dicts []*gimedic.UserDictionary) []*gimedic.UserDictionary_Entry {
+			return gimedic.Subtract(dicts[0], dicts[1:]...)
+		})
+	},
+}
+
+var dictSplitFlags struct {
+	by        string
+	prefixLen int
+	name      string
+}
+
+var dictSplitCommand = &cobra.Command{
+	Use:   "split <dict>",
+	Short: "Split a dictionary into one dictionary per POS, comment tag or key prefix",
+	Long: "Split a dictionary into new dictionaries, one for each group of its entries:\n" +
+		"  pos     by POS\n" +
+		"  tag     by the first #tag in the comment; entries without one go to \"" + gimedic.UntaggedGroup + "\"\n" +
+		"  prefix  by the first --prefix-length characters of the key\n" +
+		"The new dictionaries are named after --name with the group substituted for {}. " +
+		"The split dictionary is kept; delete it with 'dict delete' if it is no longer needed.\n" + dictRefHelp,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var group func(*gimedic.UserDictionary_Entry) string
+		switch dictSplitFlags.by {
+		case "pos":
+			group = gimedic.GroupByPart
+		case "tag":
+			group = gimedic.GroupByTag
+		case "prefix":
+			if dictSplitFlags.prefixLen < 1 {
+				return fmt.Errorf("invalid --prefix-length %d", dictSplitFlags.prefixLen)
+			}
+			group = gimedic.GroupByKeyPrefix(dictSplitFlags.prefixLen)
+		default:
+			return fmt.Errorf("unknown --by %q (available: pos, tag, prefix)", dictSplitFlags.by)
+		}
+		if !strings.Contains(dictSplitFlags.name, "{}") {
+			return fmt.Errorf("--name %q must contain {}", dictSplitFlags.name)
+		}
+		return editDictionaries(cmd, func(storage *gimedic.UserDictionaryStorage) (string, error) {
+			dict, err := resolveDictionaryRef(storage, args[0])
+			if err != nil {
+				return "", err
+			}
+			groups := gimedic.Split(dict, group)
+			names := make([]string, 0, len(groups))
+			for name := range groups {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			base := strings.ReplaceAll(dictSplitFlags.name, "{dict}", dict.GetName())
+			var lines []string
+			for _, name := range names {
+				newName := strings.ReplaceAll(base, "{}", name)
+				if err := checkDictionaryName(storage, newName); err != nil {
+					return "", err
+				}
+				created := addDictionary(storage, newName, groups[name])
+				lines = append(lines, fmt.Sprintf("SPLIT [%s] -> [%s] id=%d (%d entries)", dict.GetName(), newName, created.GetId(), len(groups[name])))
+			}
+			if len(lines) == 0 {
+				return "", fmt.Errorf("dictionary %q has no entries to split", dict.GetName())
+			}
+			return strings.Join(lines, "\n"), nil
+		})
+	},
+}
+
+func init() {
+	dictSplitCommand.Flags().StringVar(&dictSplitFlags.by, "by", "pos", "What to split by: pos, tag or prefix")
+	dictSplitCommand.Flags().IntVar(&dictSplitFlags.prefixLen, "prefix-length", 1, "Number of key characters to split by with --by prefix")
+	dictSplitCommand.Flags().StringVar(&dictSplitFlags.name, "name", "{dict}-{}", "Name of the new dictionaries; {dict} is the split dictionary and {} the group")
+	dictCommand.AddCommand(dictUnionCommand, dictIntersectCommand, dictSubtractCommand, dictSplitCommand)
+}
+
+// setOperation creates a dictionary named name from the entries op
+// computes over the referred dictionaries.
+func setOperation(cmd *cobra.Command, label, name string, refs []string, op func([]*gimedic.UserDictionary) []*gimedic.UserDictionary_Entry) error {
+	return editDictionaries(cmd, func(storage *gimedic.UserDictionaryStorage) (string, error) {
+		if err := checkDictionaryName(storage, name); err != nil {
+			return "", err
+		}
+		dicts := make([]*gimedic.UserDictionary, 0, len(refs))
+		for _, ref := range refs {
+			dict, err := resolveDictionaryRef(storage, ref)
+			if err != nil {
+				return "", err
+			}
+			dicts = append(dicts, dict)
+		}
+		entries := op(dicts)
+		created := addDictionary(storage, name, entries)
+		return fmt.Sprintf("%s [%s] -> [%s] id=%d (%d entries)", label, strings.Join(refs, "] ["), name, created.GetId(), len(entries)), nil
+	})
+}
+
+// resolveDictionaryRef finds a dictionary in storage, or in another file
+// for references of the form <file>:<dict>. A reference is read as such
+// only when the part before a ':' names an existing file, so dictionary
+// names containing ':' still work.
+func resolveDictionaryRef(storage *gimedic.UserDictionaryStorage, ref string) (*gimedic.UserDictionary, error) {
+	for i := strings.Index(ref, ":"); i >= 0; i = nextIndex(ref, ":", i) {
+		path := ref[:i]
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		source, err := loadSource(path, "", readOptions{part: gimedic.PartNoun})
+		if err != nil {
+			return nil, err
+		}
+		dict, err := findDictionary(source, ref[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return dict, nil
+	}
+	return findDictionary(storage, ref)
+}
+
+// nextIndex returns the index of the next sep in s after i, or -1.
+func nextIndex(s, sep string, i int) int {
+	j := strings.Index(s[i+1:], sep)
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+// addDictionary appends a dictionary with a fresh id holding entries.
+func addDictionary(storage *gimedic.UserDictionaryStorage, name string, entries []*gimedic.UserDictionary_Entry) *gimedic.UserDictionary {
+	id := syncer.UniqueDictionaryID(storage)
+	dict := &gimedic.UserDictionary{Id: &id, Name: &name, Entries: entries}
+	storage.Dictionaries = append(storage.Dictionaries, dict)
+	return dict
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestDictSetOperationCommands(t *testing.T) {
+	a := func() *gimedic.UserDictionary {
+		return testDictionary("a",
+			gimedic.Record{Key: "あ", Value: "亜", Pos: gimedic.PartNoun, Comment: "#x from a"},
+			gimedic.Record{Key: "い", Value: "井", Pos: gimedic.PartNoun},
+			gimedic.Record{Key: "かう", Value: "買う", Pos: gimedic.PartNoun, Comment: "#y"},
+		)
+	}
+	b := func() *gimedic.UserDictionary {
+		return testDictionary("b",
+			gimedic.Record{Key: "あ", Value: "亜", Pos: gimedic.PartNoun, Comment: "from b"},
+			gimedic.Record{Key: "い", Value: "井", Pos: gimedic.PartAdverb},
+		)
+	}
+	other := writeTestDB(t, testDictionary("c", gimedic.Record{Key: "い", Value: "井", Pos: gimedic.PartNoun}))
+	tests := []struct {
+		args    []string
+		wantErr string
+		want    []string
+	}{
+		{
+			args: []string{"dict", "union", "u", "a", "b"},
+			want: []string{"u|あ|亜|名詞|#x from a", "u|い|井|名詞|", "u|かう|買う|名詞|#y", "u|い|井|副詞|"},
+		},
+		{
+			args: []string{"dict", "intersect", "i", "b", "a"},
+			want: []string{"i|あ|亜|名詞|from b"},
+		},
+		{
+			args: []string{"dict", "subtract", "s", "a", "b"},
+			want: []string{"s|い|井|名詞|", "s|かう|買う|名詞|#y"},
+		},
+		{
+			args: []string{"dict", "subtract", "s", "a", other + ":c"},
+			want: []string{"s|あ|亜|名詞|#x from a", "s|かう|買う|名詞|#y"},
+		},
+		{args: []string{"dict", "union", "a", "a", "b"}, wantErr: "already exists"},
+		{args: []string{"dict", "union", "u", "a", "nope"}, wantErr: "not found"},
+		{args: []string{"dict", "union", "u", "a", other + ":nope"}, wantErr: other},
+		{
+			args: []string{"dict", "split", "b"},
+			want: []string{"b-副詞|い|井|副詞|", "b-名詞|あ|亜|名詞|from b"},
+		},
+		{
+			args: []string{"dict", "split", "a", "--by", "tag", "--name", "t-{}"},
+			want: []string{"t-" + gimedic.UntaggedGroup + "|い|井|名詞|", "t-x|あ|亜|名詞|#x from a", "t-y|かう|買う|名詞|#y"},
+		},
+		{
+			args: []string{"dict", "split", "a", "--by", "prefix", "--name", "{dict}-{}"},
+			want: []string{"a-あ|あ|亜|名詞|#x from a", "a-い|い|井|名詞|", "a-か|かう|買う|名詞|#y"},
+		},
+		{args: []string{"dict", "split", "a", "--by", "prefix", "--prefix-length", "0"}, wantErr: "invalid --prefix-length"},
+		{args: []string{"dict", "split", "a", "--by", "kind"}, wantErr: "unknown --by"},
+		{args: []string{"dict", "split", "a", "--name", "fixed"}, wantErr: "must contain {}"},
+		{args: []string{"dict", "split", "a"}, wantErr: "already exists"},
+	}
+	for _, test := range tests {
+		path := writeTestDB(t, a(), b(), testDictionary("a-名詞"))
+		before := dumpDB(t, path)
+		_, err := runCommand(t, append(test.args, "--path", path)...)
+		name := strings.Join(test.args, " ")
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want %q", name, err, test.wantErr)
+			}
+			if got := dumpDB(t, path); !reflect.DeepEqual(got, before) {
+				t.Errorf("%s: failed command changed the file: %v", name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		got := dumpDB(t, path)
+		if !reflect.DeepEqual(got[:len(before)], before) {
+			t.Errorf("%s: changed the source dictionaries: %v", name, got)
+		}
+		if got := got[len(before):]; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", name, got, test.want)
+		}
+	}
+}
+
+func TestResolveDictionaryRef(t *testing.T) {
+	other := writeTestDB(t, testDictionary("c:d", gimedic.Record{Key: "い", Value: "井", Pos: gimedic.PartNoun}))
+	storage := testStorage(testDictionary("x:y"), testDictionary("main"))
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{ref: "main", want: "main"},
+		{ref: "x:y", want: "x:y"},
+		{ref: other + ":c:d", want: "c:d"},
+		{ref: other + ":main", wantErr: other},
+		{ref: "nope:main", wantErr: "not found"},
+	}
+	for _, test := range tests {
+		dict, err := resolveDictionaryRef(storage, test.ref)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want %q", test.ref, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.ref, err)
+			continue
+		}
+		if dict.GetName() != test.want {
+			t.Errorf("%s: got %q, want %q", test.ref, dict.GetName(), test.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestDictCommands(t *testing.T) {
+	path := writeTestDB(t,
+		testDictionary("main", gimedic.Record{Key: "あい", Value: "愛", Pos: gimedic.PartNoun}),
+		testDictionary("sub"),
+	)
+	steps := []struct {
+		args    []string
+		wantErr string
+		want    []string
+	}{
+		{args: []string{"dict", "create", "main"}, wantErr: "already exists"},
+		{args: []string{"dict", "create", ""}, wantErr: "empty"},
+		{args: []string{"dict", "create", "new"}, want: []string{"main|あい|愛|名詞|", "sub|", "new|"}},
+		{args: []string{"dict", "rename", "nope", "x"}, wantErr: "not found"},
+		{args: []string{"dict", "rename", "sub", "main"}, wantErr: "already exists"},
+		{args: []string{"dict", "rename", "sub", "extra"}, want: []string{"main|あい|愛|名詞|", "extra|", "new|"}},
+		{args: []string{"dict", "copy", "main", "copied"}, want: []string{"main|あい|愛|名詞|", "extra|", "new|", "copied|あい|愛|名詞|"}},
+		{args: []string{"dict", "delete", "extra"}, want: []string{"main|あい|愛|名詞|", "new|", "copied|あい|愛|名詞|"}},
+	}
+	for _, step := range steps {
+		before := dumpDB(t, path)
+		_, err := runCommand(t, append(step.args, "--path", path)...)
+		name := strings.Join(step.args, " ")
+		if step.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), step.wantErr) {
+				t.Fatalf("%s: got error %v, want %q", name, err, step.wantErr)
+			}
+			if got := dumpDB(t, path); !reflect.DeepEqual(got, before) {
+				t.Fatalf("%s: failed command changed the file: %v", name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := dumpDB(t, path); !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("%s: got %v, want %v", name, got, step.want)
+		}
+	}
+
+	// Dictionaries are found by id as well as by name.
+	ids := dictionaryIDs(t, path)
+	out, err := runCommand(t, "dict", "delete", ids["copied"], "--path", path)
+	if err != nil || !strings.Contains(out, "DELETE DICTIONARY [copied]") {
+		t.Fatalf("delete by id: %q, %v", out, err)
+	}
+	out, err = runCommand(t, "dict", "list", "--path", path)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "main") {
+		t.Fatalf("unexpected list:\n%s", out)
+	}
+}
+
+// dictionaryIDs maps the names of the dictionaries onto their ids.
+func dictionaryIDs(t *testing.T, path string) map[string]string {
+	t.Helper()
+	out, err := runCommand(t, "dict", "list", "--path", path)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	ids := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n")[1:] {
+		fields := strings.Fields(line)
+		ids[fields[1]] = fields[0]
+	}
+	return ids
+}
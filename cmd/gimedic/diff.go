@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+	colorBold  = "\x1b[1m"
+)
+
+var diffFormats = map[string]func(io.Writer, diffReport) error{
+	"unified": writeUnifiedDiff,
+	"json":    writeJSONDiff,
+	"journal": writeJournalDiff,
+}
+
+// diffReport is the difference between two dictionary files.
+type diffReport struct {
+	fromPath string
+	toPath   string
+	events   []syncer.JournalEvent
+	color    bool
+}
+
+var diffCommand = &cobra.Command{
+	Use:   "diff <from> <to>",
+	Short: "Show the differences between two dictionary files",
+	Long: "Show the entries added, updated and deleted from one dictionary file to another.\n" +
+		"Either file may also be in any format accepted by encode.\n" +
+		"The journal format prints journal events that apply can replay.\n" +
+		"Like diff(1), exits with status 0 when the files are the same, 1 when they differ\n" +
+		"and 2 when the comparison fails.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		return diffTrouble(cmd, cobra.ExactArgs(2)(cmd, args))
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return diffTrouble(cmd, runDiff(cmd, args))
+	},
+}
+
+func init() {
+	diffCommand.Flags().String("format", "unified", "Output format ("+strings.Join(formatNames(diffFormats), ", ")+")")
+	diffCommand.Flags().String("color", "auto", "Colorize unified output (auto, always, never)")
+	diffCommand.Flags().StringArray("dict", nil, "Dictionary name to compare (repeatable; default: all)")
+	diffCommand.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return diffTrouble(cmd, err)
+	})
+	facadeCommand.AddCommand(diffCommand)
+}
+
+// diffTrouble gives errors other than the status telling that the files
+// differ the status 2. Cobra is silenced on every error, since main
+// prints the error and the usage would bury the difference.
+func diffTrouble(cmd *cobra.Command, err error) error {
+	if err == nil {
+		return nil
+	}
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	var status statusError
+	if errors.As(err, &status) {
+		return err
+	}
+	return statusError{status: 2, err: err}
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	write, ok := diffFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(formatNames(diffFormats), ", "))
+	}
+	colorMode, err := cmd.Flags().GetString("color")
+	if err != nil {
+		return err
+	}
+	dictNames, err := cmd.Flags().GetStringArray("dict")
+	if err != nil {
+		return err
+	}
+	report := diffReport{fromPath: args[0], toPath: args[1]}
+	if report.color, err = useColor(colorMode, cmd.OutOrStdout()); err != nil {
+		return err
+	}
+	from, err := loadSource(args[0], "", readOptions{})
+	if err != nil {
+		return err
+	}
+	to, err := loadSource(args[1], "", readOptions{})
+	if err != nil {
+		return err
+	}
+	before := filterSnapshot(syncer.SnapshotFromStorage(from), dictNames)
+	after := filterSnapshot(syncer.SnapshotFromStorage(to), dictNames)
+	report.events = syncer.DiffSnapshots(before, after)
+	if err := write(cmd.OutOrStdout(), report); err != nil {
+		return err
+	}
+	if len(report.events) > 0 {
+		return statusError{status: 1}
+	}
+	return nil
+}
+
+// filterSnapshot keeps the dictionaries named in names; an empty names
+// keeps all of them.
+func filterSnapshot(snapshot syncer.Snapshot, names []string) syncer.Snapshot {
+	if len(names) == 0 {
+		return snapshot
+	}
+	filtered := syncer.Snapshot{Dictionaries: map[string]map[string]syncer.EntryState{}}
+	for _, name := range names {
+		if entries, ok := snapshot.Dictionaries[name]; ok {
+			filtered.Dictionaries[name] = entries
+		}
+	}
+	return filtered
+}
+
+func useColor(mode string, out io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		file, ok := out.(*os.File)
+		if !ok || os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		info, err := file.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, fmt.Errorf("unknown color mode %q (available: auto, always, never)", mode)
+}
+
+func writeUnifiedDiff(w io.Writer, report diffReport) error {
+	paint := func(color, text string) string {
+		if !report.color {
+			return text
+		}
+		return color + text + colorReset
+	}
+	if len(report.events) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, paint(colorBold, "--- "+report.fromPath))
+	fmt.Fprintln(w, paint(colorBold, "+++ "+report.toPath))
+	dict := ""
+	for i, event := range report.events {
+		if i == 0 || event.Dict != dict {
+			dict = event.Dict
+			fmt.Fprintln(w, paint(colorCyan, fmt.Sprintf("@@ %s @@", dict)))
+		}
+		entry := eventEntry(event)
+		switch event.Op {
+		case "add":
+			fmt.Fprintln(w, paint(colorGreen, "+"+formatEntry(entry)))
+		case "update":
+			old := *event.Prev
+			fmt.Fprintln(w, paint(colorRed, "-"+formatEntry(entryStateEntry(old))))
+			fmt.Fprintln(w, paint(colorGreen, "+"+formatEntry(entry)))
+		case "delete":
+			fmt.Fprintln(w, paint(colorRed, "-"+formatEntry(entry)))
+		}
+	}
+	return nil
+}
+
+func writeJSONDiff(w io.Writer, report diffReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report.events)
+}
+
+func writeJournalDiff(w io.Writer, report diffReport) error {
+	encoder := json.NewEncoder(w)
+	for _, event := range report.events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestDiffCommand(t *testing.T) {
+	from := writeTestDB(t,
+		testDictionary("main", gimedic.Record{Key: "あ", Value: "亜", Pos: gimedic.PartNoun}),
+		testDictionary("other", gimedic.Record{Key: "い", Value: "井", Pos: gimedic.PartNoun}),
+	)
+	to := writeTestDB(t,
+		testDictionary("main", gimedic.Record{Key: "あ", Value: "亜", Pos: gimedic.PartNoun, Comment: "c"}),
+		testDictionary("other", gimedic.Record{Key: "い", Value: "井", Pos: gimedic.PartNoun}),
+	)
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		wantOut    string
+	}{
+		{name: "same", args: []string{"diff", from, from}, wantStatus: 0, wantOut: ""},
+		{
+			name:       "different",
+			args:       []string{"diff", from, to, "--color", "never"},
+			wantStatus: 1,
+			wantOut:    "--- " + from + "\n+++ " + to + "\n@@ main @@\n-あ\t亜\t名詞\n+あ\t亜\t名詞\tc\t\n",
+		},
+		{
+			name:       "journal",
+			args:       []string{"diff", from, to, "--format", "journal"},
+			wantStatus: 1,
+			wantOut:    `"op":"update"`,
+		},
+		{name: "other dictionary only", args: []string{"diff", from, to, "--dict", "other"}, wantStatus: 0},
+		{name: "missing file", args: []string{"diff", from, from + ".missing"}, wantStatus: 2},
+		{name: "unknown format", args: []string{"diff", from, to, "--format", "nope"}, wantStatus: 2},
+		{name: "unknown flag", args: []string{"diff", from, to, "--nope"}, wantStatus: 2},
+		{name: "missing argument", args: []string{"diff", from}, wantStatus: 2},
+	}
+	for _, test := range tests {
+		diffCommand.SilenceUsage, diffCommand.SilenceErrors = false, false
+		out, err := runCommand(t, test.args...)
+		status := 0
+		var trouble statusError
+		switch {
+		case errors.As(err, &trouble):
+			status = trouble.status
+		case err != nil:
+			status = 1
+		}
+		if err != nil && (!diffCommand.SilenceUsage || !diffCommand.SilenceErrors) {
+			t.Errorf("%s: cobra not silenced for %v", test.name, err)
+		}
+		if status != test.wantStatus {
+			t.Errorf("%s: got status %d (%v), want %d", test.name, status, err, test.wantStatus)
+		}
+		if !strings.Contains(out, test.wantOut) || test.wantOut == "" && status == 0 && out != "" {
+			t.Errorf("%s: got output %q, want %q", test.name, out, test.wantOut)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+)
+
+const editHeader = "# Edit the entries below; lines starting with # are ignored.\n" +
+	"# Removed rows are deleted and new rows are added. Save and quit to review the changes.\n"
+
+var editCommand = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit dictionaries in $EDITOR",
+	Long: "Edit dictionaries as TSV in $VISUAL or $EDITOR, review the changes and write them back.\n" +
+		"The previous file is kept with a .bak suffix.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		path, err := resolvePath(cmd, nil)
+		if err != nil {
+			return err
+		}
+		dictNames, err := cmd.Flags().GetStringArray("dict")
+		if err != nil {
+			return err
+		}
+		storage, err := syncer.LoadStorage(path)
+		if err != nil {
+			return err
+		}
+		selected := &gimedic.UserDictionaryStorage{Dictionaries: storage.GetDictionaries()}
+		if err := selectDictionaries(selected, dictNames); err != nil {
+			return err
+		}
+
+		file, err := os.CreateTemp("", "gimedic-*.tsv")
+		if err != nil {
+			return err
+		}
+		keep := false
+		defer func() {
+			if !keep {
+				os.Remove(file.Name())
+			}
+		}()
+		var buf bytes.Buffer
+		buf.WriteString(editHeader)
+		if err := gimedic.WriteTSV(&buf, selected); err != nil {
+			return err
+		}
+		if _, err := file.Write(buf.Bytes()); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		in := bufio.NewReader(cmd.InOrStdin())
+		edited, err := editUntilValid(out, in, file.Name())
+		if errors.Is(err, errEditCanceled) {
+			keep = true
+			return fmt.Errorf("%w; the edited file is kept at %s", err, file.Name())
+		}
+		if err != nil {
+			return err
+		}
+
+		before := syncer.SnapshotFromStorage(selected)
+		events := syncer.DiffSnapshots(before, syncer.SnapshotFromStorage(edited))
+		if len(events) == 0 {
+			fmt.Fprintln(out, "no changes")
+			return nil
+		}
+		printEvents(out, events)
+		ok, err := askYesNo(out, in, "Apply these changes? [y/N]: ")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("edit canceled")
+		}
+		for _, event := range events {
+			syncer.ApplyEvent(storage, event)
+		}
+		return writeBack(path, storage)
+	},
+}
+
+func init() {
+	editCommand.Flags().String("path", "", "Path to user_dictionary.db (overrides auto-detect)")
+	editCommand.Flags().StringArray("dict", nil, "Dictionary name to edit (repeatable; default: all)")
+	facadeCommand.AddCommand(editCommand)
+}
+
+// errEditCanceled is returned by editUntilValid when the user gives up
+// on a file that does not parse.
+var errEditCanceled = errors.New("edit canceled")
+
+// editUntilValid opens the file in the editor until it parses as TSV.
+// On a parse error the file is reopened with the error on its first
+// line, unless the user gives up. Line numbers in the error refer to the
+// reopened file.
+func editUntilValid(out io.Writer, in *bufio.Reader, path string) (*gimedic.UserDictionaryStorage, error) {
+	for {
+		if err := runEditor(path); err != nil {
+			return nil, err
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		content := editErrorLine.ReplaceAllString(string(raw), "")
+		storage, parseErr := gimedic.ReadTSV(strings.NewReader("\n" + blankEditComments(content)))
+		if parseErr == nil {
+			return storage, nil
+		}
+		message := strings.ReplaceAll(parseErr.Error(), "\n", " ")
+		ok, err := askYesNo(out, in, fmt.Sprintf("%s\nEdit again? [y/N]: ", message))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errEditCanceled
+		}
+		if err := os.WriteFile(path, []byte("# error: "+message+"\n"+content), 0o600); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// editErrorLine matches the error annotations of editUntilValid.
+var editErrorLine = regexp.MustCompile(`\A(# error: .*\n)+`)
+
+// blankEditComments empties the comment lines, keeping the line count.
+func blankEditComments(content string) string {
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			lines[i] = line[len(strings.TrimSuffix(line, "\n")):]
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	command := exec.Command(args[0], append(args[1:], path)...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("editor: %w", err)
+	}
+	return nil
+}
+
+// printEvents prints the changes with the entries they replace and a
+// summary line.
+func printEvents(out io.Writer, events []syncer.JournalEvent) {
+	counts := map[string]int{}
+	for _, event := range events {
+		counts[event.Op]++
+		entry := eventEntry(event)
+		switch event.Op {
+		case "add":
+			fmt.Fprintf(out, "ADD [%s] %s\n", event.Dict, formatEntry(entry))
+		case "update":
+			old := *event.Prev
+			fmt.Fprintf(out, "UPDATE [%s] %s -> %s\n", event.Dict, formatEntry(entryStateEntry(old)), formatEntry(entry))
+		case "delete":
+			fmt.Fprintf(out, "DELETE [%s] %s\n", event.Dict, formatEntry(entry))
+		}
+	}
+	fmt.Fprintf(out, "%d to add, %d to update, %d to delete\n", counts["add"], counts["update"], counts["delete"])
+}
+
+func eventEntry(event syncer.JournalEvent) *gimedic.UserDictionary_Entry {
+	return gimedic.Record{
+		Key:     event.Key,
+		Value:   event.Value,
+		Pos:     gimedic.Part(event.Pos),
+		Comment: event.Comment,
+		Locale:  event.Locale,
+	}.Entry()
+}
+
+func entryStateEntry(state syncer.EntryState) *gimedic.UserDictionary_Entry {
+	return gimedic.Record{
+		Key:     state.Key,
+		Value:   state.Value,
+		Pos:     gimedic.Part(state.Pos),
+		Comment: state.Comment,
+		Locale:  state.Locale,
+	}.Entry()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+)
+
+func TestEditUntilValid(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to act as the editor")
+	}
+	const start = "# comment\ndictionary_id\tdictionary\tkey\tvalue\tpos\tpos_name\tcomment\tlocale\n" +
+		"7\tmain\tあい\t愛\t1\t名詞\t\t\n"
+	tests := []struct {
+		name    string
+		script  string
+		answers string
+		wantErr string
+		want    []string
+	}{
+		{
+			name:   "valid",
+			script: `sed -i 's/愛/藍/' "$1"`,
+			want:   []string{"main|あい|藍|名詞|"},
+		},
+		{
+			name:    "invalid and given up",
+			script:  `echo garbage >> "$1"`,
+			answers: "n\n",
+			wantErr: "edit canceled",
+		},
+		{
+			// The second run sees the error on the first line and fixes
+			// the file.
+			name:    "invalid then fixed",
+			script:  `if grep -q '^# error:' "$1"; then sed -i '/^garbage$/d' "$1"; else echo garbage >> "$1"; fi`,
+			answers: "y\n",
+			want:    []string{"main|あい|愛|名詞|"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			script := dir + "/editor.sh"
+			if err := os.WriteFile(script, []byte(test.script+"\n"), 0o600); err != nil {
+				t.Fatalf("write script: %v", err)
+			}
+			t.Setenv("VISUAL", "sh "+script)
+			path := dir + "/edit.tsv"
+			if err := os.WriteFile(path, []byte(start), 0o600); err != nil {
+				t.Fatalf("write file: %v", err)
+			}
+			var out bytes.Buffer
+			storage, err := editUntilValid(&out, bufio.NewReader(strings.NewReader(test.answers)), path)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("editUntilValid: %v", err)
+			}
+			if got := dumpStorage(storage); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBlankEditComments(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: ""},
+		{in: "a\tb\n", want: "a\tb\n"},
+		{in: "# x\na\n# y", want: "\na\n"},
+	}
+	for _, test := range tests {
+		if got := blankEditComments(test.in); got != test.want {
+			t.Errorf("blankEditComments(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestPrintEvents(t *testing.T) {
+	before := &gimedic.UserDictionaryStorage{Dictionaries: []*gimedic.UserDictionary{testDictionary("main",
+		gimedic.Record{Key: "あい", Value: "愛", Pos: gimedic.PartNoun},
+		gimedic.Record{Key: "かお", Value: "顔", Pos: gimedic.PartNoun},
+	)}}
+	after := &gimedic.UserDictionaryStorage{Dictionaries: []*gimedic.UserDictionary{testDictionary("main",
+		gimedic.Record{Key: "あい", Value: "愛", Pos: gimedic.PartNoun, Comment: "love"},
+		gimedic.Record{Key: "め", Value: "目", Pos: gimedic.PartNoun},
+	)}}
+	events := syncer.DiffSnapshots(syncer.SnapshotFromStorage(before), syncer.SnapshotFromStorage(after))
+	var out bytes.Buffer
+	printEvents(&out, events)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := []string{
+		"UPDATE [main] あい\t愛\t名詞 -> あい\t愛\t名詞\tlove\t",
+		"ADD [main] め\t目\t名詞",
+		"DELETE [main] かお\t顔\t名詞",
+		"1 to add, 1 to update, 1 to delete",
+	}
+	got := append([]string{}, lines...)
+	if len(got) == len(want) {
+		// The order of the changes follows the snapshots, which is not
+		// the point here.
+		sort.Strings(got[:3])
+		sort.Strings(want[:3])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEditCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to act as the editor")
+	}
+	setEditor := func(t *testing.T, script string) {
+		path := t.TempDir() + "/editor.sh"
+		if err := os.WriteFile(path, []byte(script+"\n"), 0o600); err != nil {
+			t.Fatalf("write script: %v", err)
+		}
+		t.Setenv("VISUAL", "sh "+path)
+	}
+
+	t.Run("unnamed dictionary", func(t *testing.T) {
+		setEditor(t, `sed -i 's/愛/藍/' "$1"`)
+		path := writeTestDB(t, testDictionary("", gimedic.Record{Key: "あい", Value: "愛", Pos: gimedic.PartNoun}))
+		if _, err := runCommandInput(t, "y\n", "edit", "--path", path); err != nil {
+			t.Fatalf("edit: %v", err)
+		}
+		want := []string{"|あい|藍|名詞|"}
+		if got := dumpDB(t, path); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("given up keeps the file", func(t *testing.T) {
+		setEditor(t, `sed -i 's/愛/藍/' "$1"; echo garbage >> "$1"`)
+		path := writeTestDB(t, testDictionary("main", gimedic.Record{Key: "あい", Value: "愛", Pos: gimedic.PartNoun}))
+		_, err := runCommandInput(t, "\n", "edit", "--path", path)
+		if err == nil {
+			t.Fatal("edit succeeded")
+		}
+		_, kept, ok := strings.Cut(err.Error(), "kept at ")
+		if !ok {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(kept)
+		raw, err := os.ReadFile(kept)
+		if err != nil {
+			t.Fatalf("read kept file: %v", err)
+		}
+		if !strings.Contains(string(raw), "藍") {
+			t.Fatalf("kept file lost the edits:\n%s", raw)
+		}
+		want := []string{"main|あい|愛|名詞|"}
+		if got := dumpDB(t, path); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
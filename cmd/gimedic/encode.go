@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+// readOptions carries the flags that affect how text formats are read.
+type readOptions struct {
+	// dict names the dictionaries the input leaves unnamed.
+	dict string
+	// part is given to the entries of formats that carry no POS.
+	part gimedic.Part
+}
+
+type storageReader func(io.Reader, readOptions) (*gimedic.UserDictionaryStorage, error)
+
+var encodeFormats = map[string]storageReader{
+	"json":   ignoreOptions(gimedic.ReadJSON),
+	"ndjson": ignoreOptions(gimedic.ReadNDJSON),
+	"csv":    ignoreOptions(gimedic.ReadCSV),
+	"tsv":    ignoreOptions(gimedic.ReadTSV),
+	"mozc":   entryReader(gimedic.ReadMozcTSV),
+	"msime":  lossyEntryReader(gimedic.ReadMSIME),
+	"atok":   lossyEntryReader(gimedic.ReadATOK),
+	"skk":    lossyEntryReader(gimedic.ReadSKK),
+	"gboard": lossyPartEntryReader(gimedic.ReadGboard),
+	"plist":  partEntryReader(gimedic.ReadPlist),
+
+	"textproto": ignoreOptions(gimedic.ReadTextproto),
+}
+
+var encodeCommand = &cobra.Command{
+	Use:   "encode <input|->",
+	Short: "Encode a text dictionary into user_dictionary.db",
+	Long: "Encode a text dictionary into user_dictionary.db.\n" +
+		"The input uses the same schemas as decode --format emits.\n" +
+		"Pass - to read from stdin; the format is guessed from the file extension unless --format is given.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outPath, err := cmd.Flags().GetString("out")
+		if err != nil {
+			return err
+		}
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		options, err := readOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		inPath := args[0]
+		if format == "" {
+			format = formatFromExt(inPath)
+		}
+		if _, ok := encodeFormats[format]; !ok {
+			if format == "" {
+				return errors.New("cannot guess input format; use --format")
+			}
+			return fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(formatNames(encodeFormats), ", "))
+		}
+		storage, err := readTextStorage(inPath, format, options)
+		if err != nil {
+			return err
+		}
+		if outPath == "-" {
+			return writeStorageTo(cmd.OutOrStdout(), storage)
+		}
+		return syncer.WriteStorage(outPath, storage)
+	},
+}
+
+func init() {
+	encodeCommand.Flags().String("format", "", "Input format ("+strings.Join(formatNames(encodeFormats), ", ")+"; default: guessed from extension)")
+	encodeCommand.Flags().String("dict", "", "Dictionary name for entries without one (default: input file name)")
+	encodeCommand.Flags().String("pos", gimedic.PartNoun.String(), "POS label or number for formats without POS (gboard, plist)")
+	encodeCommand.Flags().String("out", "", "Output user_dictionary.db path (- for stdout)")
+	_ = encodeCommand.MarkFlagRequired("out")
+	facadeCommand.AddCommand(encodeCommand)
+}
+
+// readOptionsFromFlags reads the "dict" and "pos" flags.
+func readOptionsFromFlags(cmd *cobra.Command) (readOptions, error) {
+	dictName, err := cmd.Flags().GetString("dict")
+	if err != nil {
+		return readOptions{}, err
+	}
+	posLabel, err := cmd.Flags().GetString("pos")
+	if err != nil {
+		return readOptions{}, err
+	}
+	part, err := parsePartFlag(posLabel)
+	if err != nil {
+		return readOptions{}, err
+	}
+	return readOptions{dict: dictName, part: part}, nil
+}
+
+// parsePartFlag accepts a Part label such as 名詞 or its number.
+// PartNone is refused: Mozc does not accept entries without a POS.
+func parsePartFlag(value string) (gimedic.Part, error) {
+	part, ok := gimedic.ParsePart(value)
+	if !ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || gimedic.Part(n).String() == "unknown" {
+			return gimedic.PartNone, fmt.Errorf("unknown POS %q", value)
+		}
+		part = gimedic.Part(n)
+	}
+	if part == gimedic.PartNone {
+		return gimedic.PartNone, fmt.Errorf("POS %q (%s) cannot be used for an entry", value, gimedic.PartNone)
+	}
+	return part, nil
+}
+
+// readTextStorage reads a text dictionary in one of encodeFormats, names
+// dictionaries that have no name and assigns dictionary ids. Textproto
+// input is exact and is returned untouched.
+func readTextStorage(path, format string, options readOptions) (*gimedic.UserDictionaryStorage, error) {
+	raw, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := encodeFormats[format](bytes.NewReader(raw), options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if format == "textproto" {
+		return storage, nil
+	}
+	dictName := options.dict
+	if dictName == "" {
+		dictName = defaultDictionaryName(path)
+	}
+	for _, dict := range storage.GetDictionaries() {
+		if dict.GetName() == "" {
+			name := dictName
+			dict.Name = &name
+		}
+	}
+	assignDictionaryIDs(storage)
+	return storage, nil
+}
+
+// loadSource loads a dictionary from a user_dictionary.db or from a text
+// format. Without format, files whose extension names a text format are
+// read as such, .txt files fail with errGuessFormat and anything else is
+// read as user_dictionary.db.
+func loadSource(path, format string, options readOptions) (*gimedic.UserDictionaryStorage, error) {
+	if format == "" {
+		format = formatFromExt(path)
+		if format == "" && strings.EqualFold(filepath.Ext(path), ".txt") {
+			return nil, fmt.Errorf("%s: %w", path, errGuessFormat)
+		}
+		if _, ok := encodeFormats[format]; !ok {
+			format = "db"
+		}
+	}
+	if format == "db" {
+		raw, err := readInput(path)
+		if err != nil {
+			return nil, err
+		}
+		var storage gimedic.UserDictionaryStorage
+		if err := proto.Unmarshal(raw, &storage); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return &storage, nil
+	}
+	if _, ok := encodeFormats[format]; !ok {
+		return nil, fmt.Errorf("unknown format %q (available: db, %s)", format, strings.Join(formatNames(encodeFormats), ", "))
+	}
+	return readTextStorage(path, format, options)
+}
+
+func defaultDictionaryName(path string) string {
+	if path == "-" {
+		return "default"
+	}
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// ignoreOptions adapts a reader of a self-describing format.
+func ignoreOptions(read func(io.Reader) (*gimedic.UserDictionaryStorage, error)) storageReader {
+	return func(r io.Reader, _ readOptions) (*gimedic.UserDictionaryStorage, error) {
+		return read(r)
+	}
+}
+
+// entryReader adapts a single-dictionary reader to produce a storage
+// holding one unnamed dictionary.
+func entryReader(read func(io.Reader) ([]*gimedic.UserDictionary_Entry, error)) storageReader {
+	return partEntryReader(func(r io.Reader, _ gimedic.Part) ([]*gimedic.UserDictionary_Entry, error) {
+		return read(r)
+	})
+}
+
+// partEntryReader is like entryReader for formats without POS, which
+// give every entry the Part from the options.
+func partEntryReader(read func(io.Reader, gimedic.Part) ([]*gimedic.UserDictionary_Entry, error)) storageReader {
+	return func(r io.Reader, options readOptions) (*gimedic.UserDictionaryStorage, error) {
+		entries, err := read(r, options.part)
+		if err != nil {
+			return nil, err
+		}
+		if entries == nil {
+			entries = []*gimedic.UserDictionary_Entry{}
+		}
+		return &gimedic.UserDictionaryStorage{
+			Dictionaries: []*gimedic.UserDictionary{{Entries: entries}},
+		}, nil
+	}
+}
+
+// lossyEntryReader is like entryReader for readers that skip entries
+// they cannot convert. Those entries are reported as warnings.
+func lossyEntryReader(read func(io.Reader) ([]*gimedic.UserDictionary_Entry, []gimedic.Skipped, error)) storageReader {
+	return entryReader(func(r io.Reader) ([]*gimedic.UserDictionary_Entry, error) {
+		entries, skipped, err := read(r)
+		if err != nil {
+			return nil, err
+		}
+		warnSkipped(skipped)
+		return entries, nil
+	})
+}
+
+// lossyPartEntryReader is like lossyEntryReader for formats without POS.
+func lossyPartEntryReader(read func(io.Reader, gimedic.Part) ([]*gimedic.UserDictionary_Entry, []gimedic.Skipped, error)) storageReader {
+	return partEntryReader(func(r io.Reader, part gimedic.Part) ([]*gimedic.UserDictionary_Entry, error) {
+		entries, skipped, err := read(r, part)
+		if err != nil {
+			return nil, err
+		}
+		warnSkipped(skipped)
+		return entries, nil
+	})
+}
+
+// assignDictionaryIDs gives a fresh id to every dictionary without one
+// and to every dictionary whose id is already taken by an earlier one.
+func assignDictionaryIDs(storage *gimedic.UserDictionaryStorage) {
+	seen := map[uint64]struct{}{}
+	for _, dict := range storage.GetDictionaries() {
+		if _, dup := seen[dict.GetId()]; dup || dict.GetId() == 0 {
+			id := syncer.UniqueDictionaryID(storage)
+			dict.Id = &id
+		}
+		seen[dict.GetId()] = struct{}{}
+	}
+}
+
+// errGuessFormat tells that the format of a file cannot be told from its
+// extension.
+var errGuessFormat = errors.New("cannot guess the format from the extension")
+
+// formatFromExt guesses the format of a file from its extension: the
+// format name itself, or gboard for the .zip Gboard exports. The
+// dictionary tools of Google IME, Microsoft IME and ATOK all export .txt,
+// which is not guessed.
+func formatFromExt(path string) string {
+	switch ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."); ext {
+	case "zip":
+		return "gboard"
+	case "txt":
+		return ""
+	default:
+		return ext
+	}
+}
+
+func writeStorageTo(w io.Writer, storage *gimedic.UserDictionaryStorage) error {
+	raw, err := proto.Marshal(storage)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(raw)
+	return err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestParsePartFlag(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    gimedic.Part
+		wantErr string
+	}{
+		{value: "名詞", want: gimedic.PartNoun},
+		{value: "1", want: gimedic.PartNoun},
+		{value: "名刺", wantErr: "unknown POS"},
+		{value: "999", wantErr: "unknown POS"},
+		{value: "0", wantErr: "cannot be used"},
+		{value: "品詞なし", wantErr: "cannot be used"},
+	}
+	for _, test := range tests {
+		got, err := parsePartFlag(test.value)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got %v, %v, want error %q", test.value, got, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %v, %v, want %v", test.value, got, err, test.want)
+		}
+	}
+}
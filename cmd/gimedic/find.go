@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+)
+
+var findCommand = &cobra.Command{
+	Use:   "find <query>...",
+	Short: "Find entries matching a query",
+	Long: "Find entries matching a query and print them in any decode format.\n\n" +
+		"A query is a list of terms that must all match:\n" +
+		"  field:text      the field is text\n" +
+		"  field:text*     the field starts with text\n" +
+		"  field:/regexp/  the field matches the regular expression\n" +
+		"  text            the key, value or comment contains text\n" +
+		"Fields are key, value, pos, comment, locale and dict. A leading - negates a term,\n" +
+		"and double quotes keep spaces and special characters literal.\n" +
+		"Put -- before the query when it starts with a negated term.\n\n" +
+		"Example: gimedic find -- pos:人名 'key:あ*' '-comment:/JIRA-\\d+/'",
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		write, ok := decodeFormats[format]
+		if !ok {
+			return fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(formatNames(decodeFormats), ", "))
+		}
+		query, err := gimedic.ParseQuery(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		path, err := resolvePath(cmd, nil)
+		if err != nil {
+			return err
+		}
+		storage, err := syncer.LoadStorage(path)
+		if err != nil {
+			return err
+		}
+		return write(cmd.OutOrStdout(), query.Filter(storage))
+	},
+}
+
+func init() {
+	findCommand.Flags().String("path", "", "Path to user_dictionary.db (overrides auto-detect)")
+	findCommand.Flags().String("format", "text", "Output format ("+strings.Join(formatNames(decodeFormats), ", ")+")")
+	facadeCommand.AddCommand(findCommand)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+)
+
+var journalCommand = &cobra.Command{
+	Use:   "journal",
+	Short: "Maintain journal files",
+}
+
+var journalCompactCommand = &cobra.Command{
+	Use:   "compact [journal.jsonl]",
+	Short: "Rewrite the own journal into a checkpoint",
+	Long: "Rewrite the own journal into a checkpoint holding only the last change of each entry.\n" +
+		"Deletions older than --tombstone-ttl are dropped; a peer that has not pulled since brings such entries back.\n" +
+		"The checkpoint starts with a header that makes other peers read the journal again from the start.\n" +
+		"Compact only the journal of this machine. When a push appends to the journal meanwhile,\n" +
+		"nothing is written and the command fails; run it again.",
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		journalDir, err := cmd.Flags().GetString("journal-dir")
+		if err != nil {
+			return err
+		}
+		ttl, err := cmd.Flags().GetDuration("tombstone-ttl")
+		if err != nil {
+			return err
+		}
+		service := syncer.Service{JournalDir: journalDir}
+		journalPath, err := service.ResolveJournalPath(firstArg(args))
+		if err != nil {
+			return err
+		}
+		stats, err := syncer.CompactJournal(journalPath, ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "compacted %s: %d events -> %d (%d tombstones dropped)\n", journalPath, stats.Before, stats.After, stats.DroppedTombstones)
+		return nil
+	},
+}
+
+func init() {
+	journalCompactCommand.Flags().String("journal-dir", "", "Directory for journal files (overrides default)")
+	journalCompactCommand.Flags().Duration("tombstone-ttl", 30*24*time.Hour, "Keep deletions younger than this")
+	journalCommand.AddCommand(journalCompactCommand)
+	facadeCommand.AddCommand(journalCommand)
+}
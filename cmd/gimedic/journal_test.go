@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+)
+
+func TestJournalCompact(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := writeTestDB(t, testDictionary("main", gimedic.Record{Key: "あ", Value: "亜", Pos: gimedic.PartNoun}))
+	journalDir := t.TempDir()
+	service := syncer.Service{DBPath: path, JournalDir: journalDir, Origin: "peer-a"}
+	own, err := service.OwnJournalPath()
+	if err != nil {
+		t.Fatalf("OwnJournalPath: %v", err)
+	}
+	// Adding and then commenting the entry leaves two events of one entry.
+	if _, err := service.Push(own); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	storage, err := syncer.LoadStorage(path)
+	if err != nil {
+		t.Fatalf("LoadStorage: %v", err)
+	}
+	comment := "c"
+	storage.GetDictionaries()[0].GetEntries()[0].Comment = &comment
+	if err := syncer.WriteStorage(path, storage); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	if _, err := service.Push(own); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	out, err := runCommand(t, "journal", "compact", "--journal-dir", journalDir)
+	if err != nil {
+		t.Fatalf("journal compact: %v", err)
+	}
+	if want := "compacted " + own + ": 2 events -> 1 (0 tombstones dropped)\n"; out != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+	raw, err := os.ReadFile(own)
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	if !strings.Contains(string(raw), `"comment":"c"`) {
+		t.Fatalf("compacted journal lost the last change:\n%s", raw)
+	}
+
+	// Replaying the checkpoint gives the dictionary back.
+	replayed := writeTestDB(t)
+	replay := syncer.Service{DBPath: replayed, JournalDir: journalDir, Origin: "peer-b"}
+	if _, err := replay.Pull([]string{own}); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if got, want := dumpDB(t, replayed), dumpDB(t, path); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed %v, want %v", got, want)
+	}
+
+	if _, err := runCommand(t, "journal", "compact", journalDir+"/missing.jsonl"); err == nil {
+		t.Fatal("compacting a missing journal succeeded")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -29,7 +30,32 @@ func main() {
 		Level:   log.InfoLevel,
 	})
 	if err := facadeCommand.ExecuteContext(ctx); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		var status statusError
+		if !errors.As(err, &status) {
+			status = statusError{status: 1, err: err}
+		}
+		if status.err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(status.status)
 	}
 }
+
+// statusError ends the program with status, for commands whose status
+// carries a result. err is printed first; without one, the status alone
+// is the result and nothing is printed.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e statusError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.status)
+	}
+	return e.err.Error()
+}
+
+func (e statusError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// runCommand runs gimedic with args and returns what it printed.
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	return runCommandInput(t, "", args...)
+}
+
+// runCommandInput runs gimedic with args, answering its prompts with
+// input, and returns what it printed.
+func runCommandInput(t *testing.T, input string, args ...string) (string, error) {
+	t.Helper()
+	resetFlags(facadeCommand)
+	var out bytes.Buffer
+	facadeCommand.SetIn(strings.NewReader(input))
+	facadeCommand.SetOut(&out)
+	facadeCommand.SetErr(io.Discard)
+	facadeCommand.SetArgs(args)
+	err := facadeCommand.Execute()
+	return out.String(), err
+}
+
+// resetFlags sets the flags of the command tree back to their defaults,
+// which the commands, being package variables, keep between runs.
+func resetFlags(cmd *cobra.Command) {
+	for _, flags := range []*pflag.FlagSet{cmd.Flags(), cmd.PersistentFlags()} {
+		flags.VisitAll(func(f *pflag.Flag) {
+			if v, ok := f.Value.(pflag.SliceValue); ok {
+				_ = v.Replace(nil)
+			} else {
+				_ = f.Value.Set(f.DefValue)
+			}
+			f.Changed = false
+		})
+	}
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// testDictionary builds a dictionary of records.
+func testDictionary(name string, records ...gimedic.Record) *gimedic.UserDictionary {
+	dict := &gimedic.UserDictionary{Name: &name}
+	for _, record := range records {
+		dict.Entries = append(dict.Entries, record.Entry())
+	}
+	return dict
+}
+
+// writeTestDB writes the dictionaries to a new user_dictionary.db.
+func writeTestDB(t *testing.T, dicts ...*gimedic.UserDictionary) string {
+	t.Helper()
+	storage := &gimedic.UserDictionaryStorage{}
+	for _, dict := range dicts {
+		id := syncer.UniqueDictionaryID(storage)
+		dict.Id = &id
+		storage.Dictionaries = append(storage.Dictionaries, dict)
+	}
+	path := t.TempDir() + "/user_dictionary.db"
+	if err := syncer.WriteStorage(path, storage); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	return path
+}
+
+// dumpDB lists the entries of a user_dictionary.db as
+// "dict|key|value|pos|comment".
+func dumpDB(t *testing.T, path string) []string {
+	t.Helper()
+	storage, err := syncer.LoadStorage(path)
+	if err != nil {
+		t.Fatalf("LoadStorage: %v", err)
+	}
+	return dumpStorage(storage)
+}
+
+func dumpStorage(storage *gimedic.UserDictionaryStorage) []string {
+	lines := []string{}
+	for _, dict := range storage.GetDictionaries() {
+		if len(dict.GetEntries()) == 0 {
+			lines = append(lines, dict.GetName()+"|")
+		}
+		for _, entry := range dict.GetEntries() {
+			lines = append(lines, strings.Join([]string{dict.GetName(), entry.GetKey(), entry.GetValue(), gimedic.Part(entry.GetPos()).String(), entry.GetComment()}, "|"))
+		}
+	}
+	return lines
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -16,14 +17,44 @@ import (
 )
 
 var ingestCommand = &cobra.Command{
-	Use:   "ingest <from.db> [to.db]",
+	Use:   "ingest <from> [to.db]",
 	Short: "Ingest entries from one dictionary file into another",
-	Args:  cobra.RangeArgs(1, 2),
+	Long: "Ingest entries from one dictionary file into another.\n" +
+		"The source may be a user_dictionary.db or any format accepted by encode.\n\n" +
+		"Each change is confirmed interactively unless --yes or --dry-run is given;\n" +
+		"answer ? at the prompt for the keys that accept, skip or edit several changes at once.\n" +
+		"--strategy selects the changes proposed:\n" +
+		"  add-only  add missing dictionaries and entries only\n" +
+		"  ours      keep the target version of entries in both and add new ones only;\n" +
+		"            with --base, settle conflicts with the target version\n" +
+		"  theirs    also update entries in both to the source version\n" +
+		"  mirror    also delete target entries missing from the source (the default)\n" +
+		"Without --base, --yes deletes entries only when --strategy mirror is given\n" +
+		"explicitly; with the default it is refused unless --no-delete is given.\n\n" +
+		"With --base, the source and target are merged three-way against their common base:\n" +
+		"changes made only in the source are applied, changes made only in the target are kept,\n" +
+		"and only entries changed differently on both sides are asked about. --strategy ours or\n" +
+		"theirs settles those conflicts; with --yes, unresolved conflicts fail the merge.\n" +
+		"Quitting with q writes the changes accepted so far and keeps the target version of\n" +
+		"the conflicts left. Conflicts are written to a report.",
+	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		outPath, err := cmd.Flags().GetString("out")
 		if err != nil {
 			return err
 		}
+		fromFormat, err := cmd.Flags().GetString("from-format")
+		if err != nil {
+			return err
+		}
+		options, err := readOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		merge, err := mergeOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
 		fromPath := args[0]
 		toPath, err := resolvePath(cmd, args[1:])
 		if err != nil {
@@ -32,7 +63,10 @@ var ingestCommand = &cobra.Command{
 		if outPath == "" {
 			outPath = toPath
 		}
-		fromStorage, err := syncer.LoadStorage(fromPath)
+		fromStorage, err := loadSource(fromPath, fromFormat, options)
+		if errors.Is(err, errGuessFormat) {
+			return fmt.Errorf("%w; use --from-format", err)
+		}
 		if err != nil {
 			return err
 		}
@@ -40,16 +74,61 @@ var ingestCommand = &cobra.Command{
 		if err != nil {
 			return err
 		}
-
-		reader := bufio.NewReader(os.Stdin)
-		if err := applyMerge(cmd.OutOrStdout(), reader, fromStorage, toStorage); err != nil {
+		basePath, err := cmd.Flags().GetString("base")
+		if err != nil {
 			return err
 		}
+		if basePath == "" && merge.yes && !merge.dryRun && merge.strategy == "mirror" && !merge.noDelete && !cmd.Flags().Changed("strategy") {
+			return errors.New("--yes with the default --strategy mirror would delete target entries missing from the source without asking; " +
+				"pass --strategy mirror to delete them, or --no-delete or another strategy to keep them")
+		}
+		var actions []mergeAction
+		if basePath == "" {
+			actions = planMerge(fromStorage, toStorage, merge)
+		} else {
+			baseStorage, err := loadSource(basePath, fromFormat, options)
+			if errors.Is(err, errGuessFormat) {
+				return fmt.Errorf("%w; use --from-format", err)
+			}
+			if err != nil {
+				return err
+			}
+			actions = planThreeWay(baseStorage, fromStorage, toStorage, merge)
+		}
 
-		if outPath == toPath {
-			if err := backupFile(toPath, toPath+".bak"); err != nil {
+		out := cmd.OutOrStdout()
+		reader := bufio.NewReader(cmd.InOrStdin())
+		summary, err := applyMerge(out, reader, actions, toStorage, merge)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, summary)
+		if len(summary.conflicts) > 0 {
+			reportPath, err := cmd.Flags().GetString("conflict-report")
+			if err != nil {
 				return err
 			}
+			if reportPath == "" {
+				reportPath = outPath + ".conflicts"
+			}
+			if err := writeConflictReport(reportPath, summary.conflicts); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "conflict report written to %s\n", reportPath)
+			if n := summary.unresolved(); n > 0 && !merge.dryRun {
+				if !summary.quit {
+					return fmt.Errorf("%d unresolved conflicts; nothing written (resolve them interactively or use --strategy ours or theirs)", n)
+				}
+				fmt.Fprintf(out, "%d conflicts left unresolved; their target version is kept\n", n)
+			}
+		}
+		if merge.dryRun {
+			fmt.Fprintln(out, "dry run: nothing written")
+			return nil
+		}
+
+		if outPath == toPath {
+			return writeBack(toPath, toStorage)
 		}
 		return syncer.WriteStorage(outPath, toStorage)
 	},
@@ -58,9 +137,48 @@ var ingestCommand = &cobra.Command{
 func init() {
 	ingestCommand.Flags().String("out", "", "Output path (default: overwrite target with .bak)")
 	ingestCommand.Flags().String("path", "", "Target user_dictionary.db path (overrides auto-detect)")
+	ingestCommand.Flags().String("from-format", "", "Source format (db, "+strings.Join(formatNames(encodeFormats), ", ")+"; default: guessed from extension)")
+	ingestCommand.Flags().String("dict", "", "Dictionary name for source entries without one (default: source file name)")
+	ingestCommand.Flags().String("pos", gimedic.PartNoun.String(), "POS label or number for source formats without POS (gboard, plist)")
+	ingestCommand.Flags().String("strategy", "mirror", "Changes to propose ("+strings.Join(formatNames(mergeStrategies), ", ")+")")
+	ingestCommand.Flags().BoolP("yes", "y", false, "Apply the proposed changes without asking")
+	ingestCommand.Flags().Bool("dry-run", false, "Print the proposed changes without writing anything")
+	ingestCommand.Flags().Bool("no-delete", false, "Never delete target entries missing from the source")
+	ingestCommand.Flags().String("base", "", "Common ancestor of source and target for a three-way merge")
+	ingestCommand.Flags().String("conflict-report", "", "Where to write conflicts of a three-way merge (default: <out>.conflicts)")
 	facadeCommand.AddCommand(ingestCommand)
 }
 
+// writeBack overwrites the dictionary at path with storage, keeping the
+// previous file as path.bak.
+func writeBack(path string, storage *gimedic.UserDictionaryStorage) error {
+	if err := backupFile(path, path+".bak"); err != nil {
+		return err
+	}
+	return syncer.WriteStorage(path, storage)
+}
+
+func mergeOptionsFromFlags(cmd *cobra.Command) (mergeOptions, error) {
+	var options mergeOptions
+	var err error
+	if options.strategy, err = cmd.Flags().GetString("strategy"); err != nil {
+		return options, err
+	}
+	if _, ok := mergeStrategies[options.strategy]; !ok {
+		return options, fmt.Errorf("unknown strategy %q (available: %s)", options.strategy, strings.Join(formatNames(mergeStrategies), ", "))
+	}
+	if options.yes, err = cmd.Flags().GetBool("yes"); err != nil {
+		return options, err
+	}
+	if options.dryRun, err = cmd.Flags().GetBool("dry-run"); err != nil {
+		return options, err
+	}
+	if options.noDelete, err = cmd.Flags().GetBool("no-delete"); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
 func backupFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil {
@@ -80,120 +198,267 @@ func backupFile(src, dst string) error {
 	return out.Sync()
 }
 
-func applyMerge(out io.Writer, in *bufio.Reader, fromStorage, toStorage *gimedic.UserDictionaryStorage) error {
-	fromDicts := map[string]*gimedic.UserDictionary{}
-	for _, d := range fromStorage.GetDictionaries() {
-		fromDicts[d.GetName()] = d
+// mergeOptions controls how ingest applies the differences it finds.
+type mergeOptions struct {
+	strategy string
+	yes      bool
+	dryRun   bool
+	noDelete bool
+}
+
+// mergeStrategies are the values of --strategy; see planMerge.
+var mergeStrategies = map[string]struct{}{
+	"add-only": {},
+	"ours":     {},
+	"theirs":   {},
+	"mirror":   {},
+}
+
+// mergeAction is a single change ingest proposes.
+type mergeAction struct {
+	op       string // "add-dict", "add", "update", "delete" or "conflict"
+	dict     string
+	fromDict *gimedic.UserDictionary
+	toDict   *gimedic.UserDictionary
+	base     *gimedic.UserDictionary_Entry
+	from     *gimedic.UserDictionary_Entry
+	to       *gimedic.UserDictionary_Entry
+	// auto marks changes applied without asking.
+	auto bool
+}
+
+func (a mergeAction) String() string {
+	switch a.op {
+	case "add-dict":
+		return fmt.Sprintf("ADD DICTIONARY %q (%d entries)", a.dict, len(a.fromDict.GetEntries()))
+	case "add":
+		return fmt.Sprintf("ADD [%s] %s", a.dict, formatEntry(a.from))
+	case "update":
+		return fmt.Sprintf("UPDATE [%s] %s -> %s", a.dict, formatEntry(a.to), formatEntry(a.from))
+	case "conflict":
+		entry := a.from
+		if entry == nil {
+			entry = a.to
+		}
+		return fmt.Sprintf("CONFLICT [%s] %s\t%s\n  base:   %s\n  ours:   %s\n  theirs: %s",
+			a.dict, entry.GetKey(), entry.GetValue(), formatVersion(a.base), formatVersion(a.to), formatVersion(a.from))
+	default:
+		return fmt.Sprintf("DELETE [%s] %s", a.dict, formatEntry(a.to))
 	}
-	toDicts := map[string]*gimedic.UserDictionary{}
-	for _, d := range toStorage.GetDictionaries() {
-		toDicts[d.GetName()] = d
+}
+
+// mergeSummary counts the actions applied and skipped by op and keeps
+// the conflicts met. quit tells that the user stopped the review early.
+type mergeSummary struct {
+	applied   map[string]int
+	skipped   map[string]int
+	conflicts []mergeConflict
+	quit      bool
+}
+
+func (s mergeSummary) String() string {
+	text := fmt.Sprintf("%d dictionaries added, %d entries added, %d updated, %d deleted, %d skipped",
+		s.applied["add-dict"], s.applied["add"], s.applied["update"], s.applied["delete"],
+		s.skipped["add-dict"]+s.skipped["add"]+s.skipped["update"]+s.skipped["delete"])
+	if len(s.conflicts) > 0 {
+		text += fmt.Sprintf(", %d conflicts (%d unresolved)", len(s.conflicts), s.unresolved())
 	}
+	return text
+}
 
-	names := make([]string, 0, len(fromDicts))
-	for name := range fromDicts {
-		names = append(names, name)
+func (s mergeSummary) unresolved() int {
+	n := 0
+	for _, c := range s.conflicts {
+		if c.resolution == "" {
+			n++
+		}
 	}
-	sort.Strings(names)
+	return n
+}
 
-	for _, name := range names {
-		fromDict := fromDicts[name]
-		toDict := toDicts[name]
-		if toDict == nil {
-			msg := fmt.Sprintf("ADD DICTIONARY %q (%d entries) [y/N]: ", name, len(fromDict.GetEntries()))
-			ok, err := askYesNo(out, in, msg)
+func applyMerge(out io.Writer, in *bufio.Reader, actions []mergeAction, toStorage *gimedic.UserDictionaryStorage, options mergeOptions) (mergeSummary, error) {
+	summary := mergeSummary{applied: map[string]int{}, skipped: map[string]int{}}
+	reviewer := newReviewer(out, in)
+	for i, action := range actions {
+		if reviewer.quit {
+			// Conflicts stay pending, so that they are reported as
+			// unresolved.
+			if action.op == "conflict" {
+				summary.conflicts = append(summary.conflicts, mergeConflict{action: action})
+				continue
+			}
+			summary.skipped[action.op]++
+			continue
+		}
+		if action.op == "conflict" {
+			resolution, quit, err := resolveConflict(out, in, action, options)
 			if err != nil {
-				return err
+				return summary, err
+			}
+			reviewer.quit = quit
+			summary.conflicts = append(summary.conflicts, mergeConflict{action: action, resolution: resolution})
+			if resolution != "theirs" {
+				continue
 			}
-			if ok {
-				newDict := proto.Clone(fromDict).(*gimedic.UserDictionary)
-				newID := syncer.UniqueDictionaryID(toStorage)
-				newDict.Id = &newID
-				toStorage.Dictionaries = append(toStorage.Dictionaries, newDict)
-				toDicts[name] = newDict
+			action = action.theirs()
+		}
+		accept := action.auto || options.yes || options.dryRun
+		if !accept {
+			var err error
+			if action, accept, err = reviewer.review(action, i+1, len(actions)); err != nil {
+				return summary, err
 			}
+		} else {
+			fmt.Fprintln(out, action)
+		}
+		if !accept {
+			summary.skipped[action.op]++
 			continue
 		}
-		if err := mergeEntries(out, in, name, fromDict, toDict); err != nil {
-			return err
+		summary.applied[action.op]++
+		if !options.dryRun {
+			applyMergeAction(toStorage, action)
 		}
 	}
-	return nil
+	summary.quit = reviewer.quit
+	return summary, nil
 }
 
-func mergeEntries(out io.Writer, in *bufio.Reader, name string, fromDict, toDict *gimedic.UserDictionary) error {
-	fromEntries := map[string]*gimedic.UserDictionary_Entry{}
-	for _, e := range fromDict.GetEntries() {
-		fromEntries[entryKey(e)] = e
+// planMerge lists the changes that bring the source into the target
+// under the strategy, in dictionary name order. Without a base there are
+// no conflicts to settle, so ours keeps the target version of entries in
+// both and proposes what add-only does.
+func planMerge(fromStorage, toStorage *gimedic.UserDictionaryStorage, options mergeOptions) []mergeAction {
+	update := options.strategy == "theirs" || options.strategy == "mirror"
+	remove := options.strategy == "mirror" && !options.noDelete
+
+	toDicts := map[string]*gimedic.UserDictionary{}
+	for _, d := range toStorage.GetDictionaries() {
+		toDicts[d.GetName()] = d
 	}
-	toEntries := map[string]*gimedic.UserDictionary_Entry{}
-	for _, e := range toDict.GetEntries() {
-		toEntries[entryKey(e)] = e
+	fromDicts := map[string]*gimedic.UserDictionary{}
+	for _, d := range fromStorage.GetDictionaries() {
+		fromDicts[d.GetName()] = d
 	}
-
-	keys := make([]string, 0, len(fromEntries))
-	for key := range fromEntries {
-		keys = append(keys, key)
+	names := make([]string, 0, len(fromDicts))
+	for name := range fromDicts {
+		names = append(names, name)
 	}
-	sort.Strings(keys)
+	sort.Strings(names)
 
-	for _, key := range keys {
-		fromEntry := fromEntries[key]
-		toEntry := toEntries[key]
-		if toEntry == nil {
-			msg := fmt.Sprintf("ADD [%s] %s [y/N]: ", name, formatEntry(fromEntry))
-			ok, err := askYesNo(out, in, msg)
-			if err != nil {
-				return err
+	var actions []mergeAction
+	for _, name := range names {
+		fromDict := fromDicts[name]
+		toDict := toDicts[name]
+		if toDict == nil {
+			actions = append(actions, mergeAction{op: "add-dict", dict: name, fromDict: fromDict})
+			continue
+		}
+
+		fromEntries := map[string]*gimedic.UserDictionary_Entry{}
+		for _, e := range fromDict.GetEntries() {
+			fromEntries[entryKey(e)] = e
+		}
+		toEntries := map[string]*gimedic.UserDictionary_Entry{}
+		for _, e := range toDict.GetEntries() {
+			toEntries[entryKey(e)] = e
+		}
+		moved := pairPosChanges(fromEntries, toDict)
+		keys := make([]string, 0, len(fromEntries))
+		for key := range fromEntries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fromEntry := fromEntries[key]
+			toEntry := toEntries[key]
+			if toEntry == nil {
+				toEntry = moved[key]
 			}
-			if ok {
-				toDict.Entries = append(toDict.Entries, proto.Clone(fromEntry).(*gimedic.UserDictionary_Entry))
+			switch {
+			case toEntry == nil:
+				actions = append(actions, mergeAction{op: "add", dict: name, toDict: toDict, from: fromEntry})
+			case update && !entryEqual(fromEntry, toEntry):
+				actions = append(actions, mergeAction{op: "update", dict: name, toDict: toDict, from: fromEntry, to: toEntry})
 			}
+		}
+		if !remove {
 			continue
 		}
-		if !entryEqual(fromEntry, toEntry) {
-			msg := fmt.Sprintf("UPDATE [%s] %s -> %s [y/N]: ", name, formatEntry(toEntry), formatEntry(fromEntry))
-			ok, err := askYesNo(out, in, msg)
-			if err != nil {
-				return err
+		paired := map[*gimedic.UserDictionary_Entry]struct{}{}
+		for _, entry := range moved {
+			paired[entry] = struct{}{}
+		}
+		for _, entry := range toDict.GetEntries() {
+			if _, ok := paired[entry]; ok {
+				continue
 			}
-			if ok {
-				toEntry.Comment = fromEntry.Comment
-				toEntry.Pos = fromEntry.Pos
-				toEntry.Locale = fromEntry.Locale
+			if _, ok := fromEntries[entryKey(entry)]; !ok {
+				actions = append(actions, mergeAction{op: "delete", dict: name, toDict: toDict, to: entry})
 			}
 		}
 	}
+	return actions
+}
 
-	var deleteKeys []string
-	for key := range toEntries {
+// pairPosChanges finds the entries whose POS the source changed: a single
+// source entry missing from the target and a single target entry missing
+// from the source with the same key and value, as syncer.DiffSnapshots
+// pairs them. It maps the entryKey of the source entry onto the target
+// entry.
+func pairPosChanges(fromEntries map[string]*gimedic.UserDictionary_Entry, toDict *gimedic.UserDictionary) map[string]*gimedic.UserDictionary_Entry {
+	wordOf := func(entry *gimedic.UserDictionary_Entry) string {
+		return entry.GetKey() + "\u0000" + entry.GetValue()
+	}
+	toKeys := map[string]struct{}{}
+	missing := map[string][]*gimedic.UserDictionary_Entry{}
+	for _, entry := range toDict.GetEntries() {
+		key := entryKey(entry)
+		toKeys[key] = struct{}{}
 		if _, ok := fromEntries[key]; !ok {
-			deleteKeys = append(deleteKeys, key)
+			missing[wordOf(entry)] = append(missing[wordOf(entry)], entry)
 		}
 	}
-	sort.Strings(deleteKeys)
-	if len(deleteKeys) == 0 {
-		return nil
+	added := map[string][]string{}
+	for key, entry := range fromEntries {
+		if _, ok := toKeys[key]; !ok {
+			added[wordOf(entry)] = append(added[wordOf(entry)], key)
+		}
 	}
-
-	var kept []*gimedic.UserDictionary_Entry
-	for _, entry := range toDict.GetEntries() {
-		key := entryKey(entry)
-		if _, ok := fromEntries[key]; ok {
-			kept = append(kept, entry)
-			continue
+	moved := map[string]*gimedic.UserDictionary_Entry{}
+	for word, keys := range added {
+		if len(keys) == 1 && len(missing[word]) == 1 {
+			moved[keys[0]] = missing[word][0]
 		}
-		msg := fmt.Sprintf("DELETE [%s] %s [y/N]: ", name, formatEntry(entry))
-		ok, err := askYesNo(out, in, msg)
-		if err != nil {
-			return err
+	}
+	return moved
+}
+
+func applyMergeAction(toStorage *gimedic.UserDictionaryStorage, action mergeAction) {
+	switch action.op {
+	case "add-dict":
+		newDict := proto.Clone(action.fromDict).(*gimedic.UserDictionary)
+		newID := syncer.UniqueDictionaryID(toStorage)
+		newDict.Id = &newID
+		toStorage.Dictionaries = append(toStorage.Dictionaries, newDict)
+	case "add":
+		if action.toDict == nil {
+			action.toDict = syncer.EnsureDictionary(toStorage, action.dict)
 		}
-		if !ok {
-			kept = append(kept, entry)
+		action.toDict.Entries = append(action.toDict.Entries, proto.Clone(action.from).(*gimedic.UserDictionary_Entry))
+	case "update":
+		action.to.Comment = action.from.Comment
+		action.to.Pos = action.from.Pos
+		action.to.Locale = action.from.Locale
+	case "delete":
+		kept := action.toDict.GetEntries()[:0]
+		for _, entry := range action.toDict.GetEntries() {
+			if entry != action.to {
+				kept = append(kept, entry)
+			}
 		}
+		action.toDict.Entries = kept
 	}
-	toDict.Entries = kept
-	return nil
 }
 
 func askYesNo(out io.Writer, in *bufio.Reader, msg string) (bool, error) {
@@ -218,8 +483,10 @@ func askYesNo(out io.Writer, in *bufio.Reader, msg string) (bool, error) {
 	}
 }
 
+// entryKey identifies an entry within its dictionary by its
+// gimedic.EntryID, so entries differing only in POS are kept apart.
 func entryKey(entry *gimedic.UserDictionary_Entry) string {
-	return entry.GetKey() + "\u0000" + entry.GetValue()
+	return gimedic.EntryID(entry.GetKey(), entry.GetValue(), int32(entry.GetPos()))
 }
 
 func entryEqual(a, b *gimedic.UserDictionary_Entry) bool {
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kyoh86/gimedic"
+)
+
+// planThreeWay lists the changes that bring the source into the target
+// given their common base. Changes made only in the source are applied
+// without asking, changes made only in the target are kept, and entries
+// changed differently on both sides become conflicts.
+func planThreeWay(baseStorage, fromStorage, toStorage *gimedic.UserDictionaryStorage, options mergeOptions) []mergeAction {
+	baseDicts := dictionariesByName(baseStorage)
+	fromDicts := dictionariesByName(fromStorage)
+	toDicts := dictionariesByName(toStorage)
+	nameSet := map[string]struct{}{}
+	for _, dicts := range []map[string]*gimedic.UserDictionary{baseDicts, fromDicts, toDicts} {
+		for name := range dicts {
+			nameSet[name] = struct{}{}
+		}
+	}
+	names := formatNames(nameSet)
+
+	var actions []mergeAction
+	for _, name := range names {
+		fromDict, toDict, baseDict := fromDicts[name], toDicts[name], baseDicts[name]
+		if fromDict != nil && toDict == nil && baseDict == nil {
+			actions = append(actions, mergeAction{op: "add-dict", dict: name, fromDict: fromDict, auto: true})
+			continue
+		}
+		base := entriesByKey(baseDict)
+		from := entriesByKey(fromDict)
+		to := entriesByKey(toDict)
+		keySet := map[string]struct{}{}
+		for _, entries := range []map[string]*gimedic.UserDictionary_Entry{base, from, to} {
+			for key := range entries {
+				keySet[key] = struct{}{}
+			}
+		}
+		keys := formatNames(keySet)
+		for _, key := range keys {
+			b, f, t := base[key], from[key], to[key]
+			action := mergeAction{dict: name, toDict: toDict, base: b, from: f, to: t, auto: true}
+			switch {
+			case sameEntry(f, t), sameEntry(b, f):
+				continue
+			case !sameEntry(b, t), toDict == nil && baseDict != nil:
+				// Entries added in the source to a dictionary the target
+				// deleted conflict with the deletion as well.
+				action.op = "conflict"
+				action.auto = false
+			case t == nil:
+				action.op = "add"
+			case options.strategy == "add-only":
+				continue
+			case f == nil:
+				if options.noDelete {
+					continue
+				}
+				action.op = "delete"
+			default:
+				action.op = "update"
+			}
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+func dictionariesByName(storage *gimedic.UserDictionaryStorage) map[string]*gimedic.UserDictionary {
+	dicts := map[string]*gimedic.UserDictionary{}
+	for _, d := range storage.GetDictionaries() {
+		dicts[d.GetName()] = d
+	}
+	return dicts
+}
+
+func entriesByKey(dict *gimedic.UserDictionary) map[string]*gimedic.UserDictionary_Entry {
+	entries := map[string]*gimedic.UserDictionary_Entry{}
+	for _, e := range dict.GetEntries() {
+		entries[entryKey(e)] = e
+	}
+	return entries
+}
+
+// sameEntry reports whether two versions of an entry are the same,
+// treating a missing entry as a version of its own.
+func sameEntry(a, b *gimedic.UserDictionary_Entry) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return entryEqual(a, b)
+}
+
+// mergeConflict is a conflict and how it was resolved: "ours", "theirs"
+// or "" when it is left unresolved.
+type mergeConflict struct {
+	action     mergeAction
+	resolution string
+}
+
+// resolveConflict settles a conflict by the strategy, or by asking unless
+// the merge is non-interactive. quit reports that the user stopped the
+// review at the prompt, leaving the conflict unresolved.
+func resolveConflict(out io.Writer, in *bufio.Reader, action mergeAction, options mergeOptions) (resolution string, quit bool, err error) {
+	switch options.strategy {
+	case "ours", "add-only":
+		return "ours", false, nil
+	case "theirs":
+		if action.from == nil && options.noDelete {
+			return "ours", false, nil
+		}
+		return "theirs", false, nil
+	}
+	if options.yes || options.dryRun {
+		return "", false, nil
+	}
+	fmt.Fprintln(out, action)
+	choice, err := askChoice(out, in, "Take [o]urs or [t]heirs, or [q]uit? [o/t/q]: ", "o", "t", "q")
+	switch {
+	case err != nil, choice == "":
+		return "", false, err
+	case choice == "q":
+		return "", true, nil
+	case choice == "t":
+		return "theirs", false, nil
+	}
+	return "ours", false, nil
+}
+
+// theirs turns a conflict into the action that takes the source version.
+func (a mergeAction) theirs() mergeAction {
+	a.auto = true
+	switch {
+	case a.from == nil:
+		a.op = "delete"
+	case a.to == nil:
+		a.op = "add"
+	default:
+		a.op = "update"
+	}
+	return a
+}
+
+// askChoice asks until one of choices is answered. It returns "" at the
+// end of input.
+func askChoice(out io.Writer, in *bufio.Reader, msg string, choices ...string) (string, error) {
+	for {
+		if _, err := fmt.Fprint(out, msg); err != nil {
+			return "", err
+		}
+		line, err := in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		line = strings.ToLower(strings.TrimSpace(line))
+		for _, choice := range choices {
+			if line == choice {
+				return choice, nil
+			}
+		}
+		if err == io.EOF {
+			return "", nil
+		}
+	}
+}
+
+// formatVersion formats the fields of one side of a conflict.
+func formatVersion(entry *gimedic.UserDictionary_Entry) string {
+	if entry == nil {
+		return "(absent)"
+	}
+	return fmt.Sprintf("pos=%s comment=%q locale=%q", gimedic.Part(entry.GetPos()), entry.GetComment(), entry.GetLocale())
+}
+
+// writeConflictReport writes every conflict with its three versions and
+// resolution.
+func writeConflictReport(path string, conflicts []mergeConflict) error {
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].action.dict < conflicts[j].action.dict
+	})
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	for _, c := range conflicts {
+		resolution := c.resolution
+		if resolution == "" {
+			resolution = "unresolved"
+		}
+		fmt.Fprintf(writer, "%s\n  resolution: %s\n", c.action, resolution)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestPlanThreeWay(t *testing.T) {
+	noun := gimedic.PartNoun
+	entry := func(key, comment string) gimedic.Record {
+		return gimedic.Record{Key: key, Value: key, Pos: noun, Comment: comment}
+	}
+	base := func() *gimedic.UserDictionaryStorage {
+		return testStorage(
+			testDictionary("main", entry("same", ""), entry("theirs", ""), entry("ours", ""), entry("both", ""), entry("gone", "")),
+			testDictionary("dropped", entry("kept", "")),
+		)
+	}
+	from := func() *gimedic.UserDictionaryStorage {
+		return testStorage(
+			testDictionary("main", entry("same", ""), entry("theirs", "source"), entry("ours", ""), entry("both", "source"), entry("added", "")),
+			testDictionary("dropped", entry("kept", ""), entry("late", "")),
+			testDictionary("fresh", entry("new", "")),
+		)
+	}
+	to := func() *gimedic.UserDictionaryStorage {
+		return testStorage(
+			testDictionary("main", entry("same", ""), entry("theirs", ""), entry("ours", "target"), entry("both", "target"), entry("gone", "")),
+		)
+	}
+	tests := []struct {
+		options mergeOptions
+		want    []string
+	}{
+		{
+			// Source-only changes are applied, target-only ones kept; the
+			// entry added to a dictionary the target deleted conflicts
+			// with the deletion.
+			options: mergeOptions{strategy: "mirror"},
+			want: []string{
+				"conflict dropped late=late(名詞)",
+				"add-dict fresh",
+				"add main added=added(名詞)",
+				"conflict main both=both(名詞)",
+				"delete main gone=gone(名詞)",
+				"update main theirs=theirs(名詞)",
+			},
+		},
+		{
+			options: mergeOptions{strategy: "mirror", noDelete: true},
+			want: []string{
+				"conflict dropped late=late(名詞)",
+				"add-dict fresh",
+				"add main added=added(名詞)",
+				"conflict main both=both(名詞)",
+				"update main theirs=theirs(名詞)",
+			},
+		},
+		{
+			options: mergeOptions{strategy: "add-only"},
+			want: []string{
+				"conflict dropped late=late(名詞)",
+				"add-dict fresh",
+				"add main added=added(名詞)",
+				"conflict main both=both(名詞)",
+			},
+		},
+	}
+	for _, test := range tests {
+		actions := planThreeWay(base(), from(), to(), test.options)
+		if got := describeActions(actions); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: got %v, want %v", test.options, got, test.want)
+		}
+		for _, action := range actions {
+			if action.auto == (action.op == "conflict") {
+				t.Errorf("%+v: %s: auto=%v", test.options, action.op, action.auto)
+			}
+		}
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	entry := gimedic.Record{Key: "k", Value: "v", Pos: gimedic.PartNoun}.Entry()
+	modified := mergeAction{op: "conflict", dict: "main", from: entry, to: entry}
+	deleted := mergeAction{op: "conflict", dict: "main", to: entry}
+	tests := []struct {
+		name    string
+		action  mergeAction
+		options mergeOptions
+		answers string
+		want    string
+		quit    bool
+	}{
+		{name: "ours", action: modified, options: mergeOptions{strategy: "ours"}, want: "ours"},
+		{name: "add-only", action: modified, options: mergeOptions{strategy: "add-only"}, want: "ours"},
+		{name: "theirs", action: modified, options: mergeOptions{strategy: "theirs"}, want: "theirs"},
+		{name: "theirs without deleting", action: deleted, options: mergeOptions{strategy: "theirs", noDelete: true}, want: "ours"},
+		{name: "yes", action: modified, options: mergeOptions{strategy: "mirror", yes: true}, want: ""},
+		{name: "asked", action: modified, options: mergeOptions{strategy: "mirror"}, answers: "x\nt\n", want: "theirs"},
+		{name: "asked until the end of input", action: modified, options: mergeOptions{strategy: "mirror"}, answers: "x\n", want: ""},
+		{name: "quit", action: modified, options: mergeOptions{strategy: "mirror"}, answers: "q\n", want: "", quit: true},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		got, quit, err := resolveConflict(&out, bufio.NewReader(strings.NewReader(test.answers)), test.action, test.options)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got != test.want || quit != test.quit {
+			t.Errorf("%s: got %q (quit %v), want %q (quit %v)", test.name, got, quit, test.want, test.quit)
+		}
+	}
+}
+
+func TestIngestQuitAtConflict(t *testing.T) {
+	noun := gimedic.PartNoun
+	base := writeTestDB(t, testDictionary("main", gimedic.Record{Key: "c", Value: "c", Pos: noun, Comment: "base"}))
+	from := writeTestDB(t, testDictionary("main",
+		gimedic.Record{Key: "c", Value: "c", Pos: noun, Comment: "theirs"},
+		gimedic.Record{Key: "a", Value: "a", Pos: noun},
+	))
+	to := writeTestDB(t, testDictionary("main", gimedic.Record{Key: "c", Value: "c", Pos: noun, Comment: "ours"}))
+	out, err := runCommandInput(t, "q\n", "ingest", from, to, "--base", base)
+	if err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if !strings.Contains(out, "1 conflicts left unresolved") {
+		t.Fatalf("unresolved conflict not reported:\n%s", out)
+	}
+	want := []string{"main|c|c|名詞|ours", "main|a|a|名詞|"}
+	if got := dumpDB(t, to); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	report, err := os.ReadFile(to + ".conflicts")
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	if !strings.Contains(string(report), "resolution: unresolved") {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+)
+
+// describeActions lists the actions as "op dict key=value(pos)".
+func describeActions(actions []mergeAction) []string {
+	described := []string{}
+	for _, a := range actions {
+		entry := a.from
+		if entry == nil {
+			entry = a.to
+		}
+		if a.op == "add-dict" {
+			described = append(described, a.op+" "+a.dict)
+			continue
+		}
+		described = append(described, a.op+" "+a.dict+" "+entry.GetKey()+"="+entry.GetValue()+"("+gimedic.Part(entry.GetPos()).String()+")")
+	}
+	return described
+}
+
+func testStorage(dicts ...*gimedic.UserDictionary) *gimedic.UserDictionaryStorage {
+	return &gimedic.UserDictionaryStorage{Dictionaries: dicts}
+}
+
+func TestPlanMerge(t *testing.T) {
+	noun := gimedic.PartNoun
+	from := func() *gimedic.UserDictionaryStorage {
+		return testStorage(
+			testDictionary("main",
+				gimedic.Record{Key: "あ", Value: "亜", Pos: noun, Comment: "new"},
+				gimedic.Record{Key: "い", Value: "井", Pos: noun},
+				gimedic.Record{Key: "う", Value: "宇", Pos: gimedic.PartSuruNoun},
+			),
+			testDictionary("extra", gimedic.Record{Key: "え", Value: "江", Pos: noun}),
+		)
+	}
+	to := func() *gimedic.UserDictionaryStorage {
+		return testStorage(testDictionary("main",
+			gimedic.Record{Key: "あ", Value: "亜", Pos: noun, Comment: "old"},
+			gimedic.Record{Key: "う", Value: "宇", Pos: noun},
+			gimedic.Record{Key: "お", Value: "尾", Pos: noun},
+		))
+	}
+	tests := []struct {
+		options mergeOptions
+		want    []string
+	}{
+		{
+			options: mergeOptions{strategy: "add-only"},
+			want:    []string{"add-dict extra", "add main い=井(名詞)"},
+		},
+		{
+			options: mergeOptions{strategy: "ours"},
+			want:    []string{"add-dict extra", "add main い=井(名詞)"},
+		},
+		{
+			// The POS change of う is an update, not an add of a
+			// homograph.
+			options: mergeOptions{strategy: "theirs"},
+			want:    []string{"add-dict extra", "update main あ=亜(名詞)", "add main い=井(名詞)", "update main う=宇(名詞サ変)"},
+		},
+		{
+			options: mergeOptions{strategy: "mirror"},
+			want:    []string{"add-dict extra", "update main あ=亜(名詞)", "add main い=井(名詞)", "update main う=宇(名詞サ変)", "delete main お=尾(名詞)"},
+		},
+		{
+			options: mergeOptions{strategy: "mirror", noDelete: true},
+			want:    []string{"add-dict extra", "update main あ=亜(名詞)", "add main い=井(名詞)", "update main う=宇(名詞サ変)"},
+		},
+	}
+	for _, test := range tests {
+		actions := planMerge(from(), to(), test.options)
+		if got := describeActions(actions); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: got %v, want %v", test.options, got, test.want)
+		}
+	}
+}
+
+func TestPlanMergeHomographs(t *testing.T) {
+	// Two homographs on one side leave nothing to pair: each POS is an
+	// entry of its own.
+	from := testStorage(testDictionary("main",
+		gimedic.Record{Key: "あい", Value: "愛", Pos: gimedic.PartNoun},
+		gimedic.Record{Key: "あい", Value: "愛", Pos: gimedic.PartSuruNoun},
+	))
+	to := testStorage(testDictionary("main",
+		gimedic.Record{Key: "あい", Value: "愛", Pos: gimedic.PartAdverb},
+	))
+	got := describeActions(planMerge(from, to, mergeOptions{strategy: "mirror"}))
+	want := []string{"add main あい=愛(名詞)", "add main あい=愛(名詞サ変)", "delete main あい=愛(副詞)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestIngestTwoWayStrategies(t *testing.T) {
+	noun := gimedic.PartNoun
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+		want    []string
+	}{
+		{
+			name: "ours keeps the target",
+			args: []string{"--strategy", "ours", "--yes"},
+			want: []string{"main|あ|亜|名詞|old", "main|う|宇|名詞|", "main|い|井|名詞|"},
+		},
+		{
+			name:    "default mirror refuses --yes",
+			args:    []string{"--yes"},
+			wantErr: "--strategy mirror",
+			want:    []string{"main|あ|亜|名詞|old", "main|う|宇|名詞|"},
+		},
+		{
+			name: "default mirror with --no-delete",
+			args: []string{"--yes", "--no-delete"},
+			want: []string{"main|あ|亜|名詞|new", "main|う|宇|名詞|", "main|い|井|名詞|"},
+		},
+		{
+			name: "explicit mirror deletes",
+			args: []string{"--strategy", "mirror", "--yes"},
+			want: []string{"main|あ|亜|名詞|new", "main|い|井|名詞|"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from := writeTestDB(t, testDictionary("main",
+				gimedic.Record{Key: "あ", Value: "亜", Pos: noun, Comment: "new"},
+				gimedic.Record{Key: "い", Value: "井", Pos: noun},
+			))
+			to := writeTestDB(t, testDictionary("main",
+				gimedic.Record{Key: "あ", Value: "亜", Pos: noun, Comment: "old"},
+				gimedic.Record{Key: "う", Value: "宇", Pos: noun},
+			))
+			_, err := runCommand(t, append([]string{"ingest", from, to}, test.args...)...)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("ingest: %v", err)
+			}
+			if got := dumpDB(t, to); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIngestUnknownStrategy(t *testing.T) {
+	path := writeTestDB(t, testDictionary("main", gimedic.Record{Key: "あ", Value: "亜", Pos: gimedic.PartNoun}))
+	_, err := runCommand(t, "ingest", path, path, "--strategy", "newest", "--dry-run")
+	if err == nil || !strings.Contains(err.Error(), `unknown strategy "newest"`) {
+		t.Fatalf("got error %v, want the strategy refused", err)
+	}
+}
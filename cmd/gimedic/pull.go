@@ -25,6 +25,7 @@ var pullCommand = &cobra.Command{
 		service := syncer.Service{
 			DBPath:     dbPath,
 			JournalDir: journalDir,
+			Warn:       func(msg string) { log.Warnf("pull: %s", msg) },
 		}
 		journalPaths, err := service.ResolveJournalPaths(args)
 		if err != nil {
@@ -45,6 +46,13 @@ var pullCommand = &cobra.Command{
 		if applied > 0 {
 			log.Infof("pull: applied %d events", applied)
 		}
+		conflicts, err := syncer.LoadConflicts(dbPath)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			log.Warnf("pull: %d unresolved conflicts; see 'gimedic conflicts list'", len(conflicts))
+		}
 		return nil
 	},
 }
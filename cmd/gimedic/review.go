@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kyoh86/gimedic"
+	"google.golang.org/protobuf/proto"
+)
+
+const reviewHelp = `y - accept this change
+n - skip this change
+a - accept this and every remaining change of the same kind
+s - skip this and the remaining changes of this dictionary
+q - stop here and save the changes accepted so far
+e - edit the POS and comment (- clears the comment), then accept (ADD and UPDATE only)
+? - show this help
+`
+
+// reviewer asks about each change of an interactive ingest and remembers
+// the answers that cover later changes.
+type reviewer struct {
+	out       io.Writer
+	in        *bufio.Reader
+	acceptAll map[string]bool
+	skipDict  map[string]bool
+	quit      bool
+}
+
+func newReviewer(out io.Writer, in *bufio.Reader) *reviewer {
+	return &reviewer{out: out, in: in, acceptAll: map[string]bool{}, skipDict: map[string]bool{}}
+}
+
+// review asks whether to apply the action, numbered n of total. It
+// returns the action to apply, which the user may have edited.
+func (r *reviewer) review(action mergeAction, n, total int) (mergeAction, bool, error) {
+	if r.quit || r.skipDict[action.dict] {
+		return action, false, nil
+	}
+	if r.acceptAll[action.op] {
+		fmt.Fprintf(r.out, "[%d/%d] %s\n", n, total, action)
+		return action, true, nil
+	}
+	r.show(action, n, total)
+	for {
+		fmt.Fprint(r.out, "[y,n,a,s,q,e,?]: ")
+		line, err := r.in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return action, false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y":
+			return action, true, nil
+		case "n":
+			return action, false, nil
+		case "a":
+			r.acceptAll[action.op] = true
+			return action, true, nil
+		case "s":
+			r.skipDict[action.dict] = true
+			return action, false, nil
+		case "q":
+			r.quit = true
+			return action, false, nil
+		case "e":
+			if action.op != "add" && action.op != "update" {
+				fmt.Fprintln(r.out, "only ADD and UPDATE can be edited")
+				continue
+			}
+			edited, err := r.edit(action)
+			return edited, err == nil, err
+		case "?":
+			fmt.Fprint(r.out, reviewHelp)
+			continue
+		}
+		if err == io.EOF {
+			return action, false, nil
+		}
+		if strings.TrimSpace(line) == "" {
+			return action, false, nil
+		}
+		fmt.Fprint(r.out, reviewHelp)
+	}
+}
+
+// show prints the action; updates get a field by field comparison with
+// the changed fields marked.
+func (r *reviewer) show(action mergeAction, n, total int) {
+	if action.op != "update" {
+		fmt.Fprintf(r.out, "[%d/%d] %s\n", n, total, action)
+		return
+	}
+	fmt.Fprintf(r.out, "[%d/%d] UPDATE [%s] %s\t%s\n", n, total, action.dict, action.to.GetKey(), action.to.GetValue())
+	rows := [][3]string{
+		{"pos", gimedic.Part(action.to.GetPos()).String(), gimedic.Part(action.from.GetPos()).String()},
+		{"comment", singleLineText(action.to.GetComment()), singleLineText(action.from.GetComment())},
+		{"locale", action.to.GetLocale(), action.from.GetLocale()},
+	}
+	width := len("target")
+	for _, row := range rows {
+		width = max(width, displayWidth(row[1]))
+	}
+	fmt.Fprintf(r.out, "  %-8s %s  %s\n", "", padRight("target", width), "source")
+	for _, row := range rows {
+		mark := " "
+		if row[1] != row[2] {
+			mark = "*"
+		}
+		fmt.Fprintf(r.out, "%s %-8s %s  %s\n", mark, row[0], padRight(row[1], width), row[2])
+	}
+}
+
+// edit asks for the POS and comment of the entry to add.
+func (r *reviewer) edit(action mergeAction) (mergeAction, error) {
+	entry := proto.Clone(action.from).(*gimedic.UserDictionary_Entry)
+	for {
+		fmt.Fprintf(r.out, "POS [%s]: ", gimedic.Part(entry.GetPos()))
+		line, err := r.in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return action, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		part, perr := parsePartFlag(line)
+		if perr == nil {
+			pos := gimedic.UserDictionary_PosType(part)
+			entry.Pos = &pos
+			break
+		}
+		fmt.Fprintln(r.out, perr)
+		if err == io.EOF {
+			break
+		}
+	}
+	fmt.Fprintf(r.out, "Comment [%s] (- to clear): ", singleLineText(entry.GetComment()))
+	line, err := r.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return action, err
+	}
+	switch line = strings.TrimRight(line, "\r\n"); line {
+	case "":
+	case "-":
+		entry.Comment = proto.String("")
+	default:
+		entry.Comment = &line
+	}
+	action.from = entry
+	return action, nil
+}
+
+func singleLineText(s string) string {
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// displayWidth approximates the terminal width of s, counting wide
+// characters such as kanji and kana as two columns.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r >= 0x1100 && (r <= 0x115f || r >= 0x2e80 && r <= 0xa4cf || r >= 0xac00 && r <= 0xd7a3 ||
+			r >= 0xf900 && r <= 0xfaff || r >= 0xfe30 && r <= 0xfe4f || r >= 0xff00 && r <= 0xff60 || r >= 0xffe0 && r <= 0xffe6) {
+			width += 2
+			continue
+		}
+		width++
+	}
+	return width
+}
+
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-displayWidth(s)))
+}
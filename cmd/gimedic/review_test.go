@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestReviewerReview(t *testing.T) {
+	entry := func(key string) *gimedic.UserDictionary_Entry {
+		return gimedic.Record{Key: key, Value: key, Pos: gimedic.PartNoun}.Entry()
+	}
+	add := func(dict, key string) mergeAction {
+		return mergeAction{op: "add", dict: dict, from: entry(key)}
+	}
+	del := func(dict, key string) mergeAction {
+		return mergeAction{op: "delete", dict: dict, to: entry(key)}
+	}
+	tests := []struct {
+		name    string
+		actions []mergeAction
+		answers string
+		want    []bool
+	}{
+		{name: "yes and no", actions: []mergeAction{add("a", "1"), add("a", "2")}, answers: "y\nn\n", want: []bool{true, false}},
+		{name: "help then yes", actions: []mergeAction{add("a", "1")}, answers: "?\ny\n", want: []bool{true}},
+		{name: "empty answer skips", actions: []mergeAction{add("a", "1")}, answers: "\n", want: []bool{false}},
+		{name: "end of input skips", actions: []mergeAction{add("a", "1")}, answers: "", want: []bool{false}},
+		{
+			name:    "accept all of a kind",
+			actions: []mergeAction{add("a", "1"), del("a", "2"), add("b", "3")},
+			answers: "a\nn\n",
+			want:    []bool{true, false, true},
+		},
+		{
+			name:    "skip a dictionary",
+			actions: []mergeAction{add("a", "1"), del("a", "2"), add("b", "3")},
+			answers: "s\ny\n",
+			want:    []bool{false, false, true},
+		},
+		{
+			name:    "quit",
+			actions: []mergeAction{add("a", "1"), add("a", "2"), add("b", "3")},
+			answers: "q\n",
+			want:    []bool{false, false, false},
+		},
+		{
+			name:    "deletes cannot be edited",
+			actions: []mergeAction{del("a", "1")},
+			answers: "e\ny\n",
+			want:    []bool{true},
+		},
+	}
+	for _, test := range tests {
+		r := newReviewer(io.Discard, bufio.NewReader(strings.NewReader(test.answers)))
+		var got []bool
+		for i, action := range test.actions {
+			_, ok, err := r.review(action, i+1, len(test.actions))
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			got = append(got, ok)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReviewerEdit(t *testing.T) {
+	r := newReviewer(io.Discard, bufio.NewReader(strings.NewReader("e\nnope\n副詞\nedited\n")))
+	action := mergeAction{op: "add", dict: "a", from: gimedic.Record{Key: "k", Value: "v", Pos: gimedic.PartNoun}.Entry()}
+	edited, ok, err := r.review(action, 1, 1)
+	if err != nil || !ok {
+		t.Fatalf("review: %v, %v", ok, err)
+	}
+	if gimedic.Part(edited.from.GetPos()) != gimedic.PartAdverb || edited.from.GetComment() != "edited" {
+		t.Fatalf("unexpected edit: %v", edited.from)
+	}
+	if action.from.GetComment() != "" {
+		t.Fatal("edit changed the proposed entry")
+	}
+}
+
+func TestReviewerEditClearsComment(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{answer: "\n", want: "old"},
+		{answer: "-\n", want: ""},
+		{answer: "new\n", want: "new"},
+	}
+	for _, test := range tests {
+		r := newReviewer(io.Discard, bufio.NewReader(strings.NewReader("e\n\n"+test.answer)))
+		action := mergeAction{op: "add", dict: "a", from: gimedic.Record{Key: "k", Value: "v", Pos: gimedic.PartNoun, Comment: "old"}.Entry()}
+		edited, ok, err := r.review(action, 1, 1)
+		if err != nil || !ok {
+			t.Fatalf("review: %v, %v", ok, err)
+		}
+		if got := edited.from.GetComment(); got != test.want {
+			t.Errorf("answer %q: got comment %q, want %q", test.answer, got, test.want)
+		}
+	}
+}
+
+func TestApplyMerge(t *testing.T) {
+	noun := gimedic.PartNoun
+	newActions := func(to *gimedic.UserDictionaryStorage) []mergeAction {
+		dict := to.GetDictionaries()[0]
+		conflict := mergeAction{
+			op:     "conflict",
+			dict:   "main",
+			toDict: dict,
+			from:   gimedic.Record{Key: "c", Value: "c", Pos: noun, Comment: "theirs"}.Entry(),
+			to:     dict.GetEntries()[0],
+		}
+		return []mergeAction{
+			{op: "add", dict: "main", toDict: dict, from: gimedic.Record{Key: "a", Value: "a", Pos: noun}.Entry()},
+			{op: "add", dict: "main", toDict: dict, from: gimedic.Record{Key: "b", Value: "b", Pos: noun}.Entry()},
+			conflict,
+		}
+	}
+	newTarget := func() *gimedic.UserDictionaryStorage {
+		return testStorage(testDictionary("main", gimedic.Record{Key: "c", Value: "c", Pos: noun, Comment: "ours"}))
+	}
+	tests := []struct {
+		name           string
+		options        mergeOptions
+		answers        string
+		wantApplied    int
+		wantSkipped    int
+		wantUnresolved int
+		wantQuit       bool
+		want           []string
+	}{
+		{
+			// Quitting leaves the conflict pending, not skipped.
+			name:           "quit with a pending conflict",
+			options:        mergeOptions{strategy: "mirror"},
+			answers:        "y\nq\n",
+			wantApplied:    1,
+			wantSkipped:    1,
+			wantUnresolved: 1,
+			wantQuit:       true,
+			want:           []string{"main|c|c|名詞|ours", "main|a|a|名詞|"},
+		},
+		{
+			name:           "quit at a conflict",
+			options:        mergeOptions{strategy: "mirror"},
+			answers:        "y\ny\nq\n",
+			wantApplied:    2,
+			wantUnresolved: 1,
+			wantQuit:       true,
+			want:           []string{"main|c|c|名詞|ours", "main|a|a|名詞|", "main|b|b|名詞|"},
+		},
+		{
+			name:        "conflict taken from the source",
+			options:     mergeOptions{strategy: "mirror"},
+			answers:     "y\ny\nt\n",
+			wantApplied: 3,
+			want:        []string{"main|c|c|名詞|theirs", "main|a|a|名詞|", "main|b|b|名詞|"},
+		},
+		{
+			name:           "yes leaves conflicts unresolved",
+			options:        mergeOptions{strategy: "mirror", yes: true},
+			wantApplied:    2,
+			wantUnresolved: 1,
+			want:           []string{"main|c|c|名詞|ours", "main|a|a|名詞|", "main|b|b|名詞|"},
+		},
+		{
+			name:        "dry run writes nothing",
+			options:     mergeOptions{strategy: "theirs", dryRun: true},
+			wantApplied: 3,
+			want:        []string{"main|c|c|名詞|ours"},
+		},
+	}
+	for _, test := range tests {
+		to := newTarget()
+		summary, err := applyMerge(io.Discard, bufio.NewReader(strings.NewReader(test.answers)), newActions(to), to, test.options)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		applied, skipped := 0, 0
+		for _, n := range summary.applied {
+			applied += n
+		}
+		for _, n := range summary.skipped {
+			skipped += n
+		}
+		if applied != test.wantApplied || skipped != test.wantSkipped || summary.unresolved() != test.wantUnresolved || summary.quit != test.wantQuit {
+			t.Errorf("%s: applied %d, skipped %d, unresolved %d; summary %s", test.name, applied, skipped, summary.unresolved(), summary)
+		}
+		if got := dumpStorage(to); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+)
+
+var rmCommand = &cobra.Command{
+	Use:   "rm <key> [value]",
+	Short: "Remove entries from dictionaries",
+	Long: "Remove the entries with the key, and the value when given.\n" +
+		"Without --dict every dictionary is searched. The previous file is kept with a .bak suffix.",
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := resolvePath(cmd, nil)
+		if err != nil {
+			return err
+		}
+		dictName, err := cmd.Flags().GetString("dict")
+		if err != nil {
+			return err
+		}
+		key, value := args[0], ""
+		if len(args) > 1 {
+			value = args[1]
+		}
+		storage, err := syncer.LoadStorage(path)
+		if err != nil {
+			return err
+		}
+		matches := matchingEntries(storage, dictName, key, value)
+		if len(matches) == 0 {
+			return fmt.Errorf("no entry for %q", key)
+		}
+		removed := map[*gimedic.UserDictionary_Entry]bool{}
+		for _, m := range matches {
+			removed[m.entry] = true
+		}
+		for _, dict := range storage.GetDictionaries() {
+			kept := dict.GetEntries()[:0]
+			for _, entry := range dict.GetEntries() {
+				if !removed[entry] {
+					kept = append(kept, entry)
+				}
+			}
+			dict.Entries = kept
+		}
+		if err := writeBack(path, storage); err != nil {
+			return err
+		}
+		for _, m := range matches {
+			fmt.Fprintf(cmd.OutOrStdout(), "DELETE [%s] %s\n", m.dict.GetName(), formatEntry(m.entry))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rmCommand.Flags().String("path", "", "Path to user_dictionary.db (overrides auto-detect)")
+	rmCommand.Flags().String("dict", "", "Dictionary name (default: all)")
+	facadeCommand.AddCommand(rmCommand)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kyoh86/gimedic"
+	"github.com/kyoh86/gimedic/internal/syncer"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+var setCommand = &cobra.Command{
+	Use:   "set <key> [value]",
+	Short: "Modify entries in dictionaries",
+	Long: "Set the POS, comment or locale of the entries with the key, and the value when given.\n" +
+		"Only the fields whose flags are given are changed. Without --dict every dictionary is searched.\n" +
+		"The previous file is kept with a .bak suffix.",
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := resolvePath(cmd, nil)
+		if err != nil {
+			return err
+		}
+		dictName, err := cmd.Flags().GetString("dict")
+		if err != nil {
+			return err
+		}
+		flags := cmd.Flags()
+		if !flags.Changed("pos") && !flags.Changed("comment") && !flags.Changed("locale") {
+			return errors.New("nothing to set; give --pos, --comment or --locale")
+		}
+		record, err := recordFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		key, value := args[0], ""
+		if len(args) > 1 {
+			value = args[1]
+		}
+		storage, err := syncer.LoadStorage(path)
+		if err != nil {
+			return err
+		}
+		matches := matchingEntries(storage, dictName, key, value)
+		if len(matches) == 0 {
+			return fmt.Errorf("no entry for %q", key)
+		}
+		var changes []string
+		for _, m := range matches {
+			before := proto.Clone(m.entry).(*gimedic.UserDictionary_Entry)
+			if flags.Changed("pos") {
+				pos := gimedic.UserDictionary_PosType(record.Pos)
+				m.entry.Pos = &pos
+			}
+			if flags.Changed("comment") {
+				comment := record.Comment
+				m.entry.Comment = &comment
+			}
+			if flags.Changed("locale") {
+				locale := record.Locale
+				m.entry.Locale = &locale
+			}
+			if !entryEqual(before, m.entry) {
+				changes = append(changes, fmt.Sprintf("UPDATE [%s] %s -> %s", m.dict.GetName(), formatEntry(before), formatEntry(m.entry)))
+			}
+		}
+		for _, m := range matches {
+			if err := checkDuplicateEntries(m.dict); err != nil {
+				return err
+			}
+		}
+		if len(changes) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "no changes")
+			return nil
+		}
+		if err := writeBack(path, storage); err != nil {
+			return err
+		}
+		for _, change := range changes {
+			fmt.Fprintln(cmd.OutOrStdout(), change)
+		}
+		return nil
+	},
+}
+
+func init() {
+	setCommand.Flags().String("path", "", "Path to user_dictionary.db (overrides auto-detect)")
+	setCommand.Flags().String("dict", "", "Dictionary name (default: all)")
+	addEntryFlags(setCommand)
+	facadeCommand.AddCommand(setCommand)
+}
+
+// checkDuplicateEntries refuses a dictionary holding two entries with the
+// same key, value and POS, which setting the POS of homographs may cause.
+func checkDuplicateEntries(dict *gimedic.UserDictionary) error {
+	seen := map[string]struct{}{}
+	for _, entry := range dict.GetEntries() {
+		key := entryKey(entry)
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("[%s] %s would be duplicated", dict.GetName(), formatEntry(entry))
+		}
+		seen[key] = struct{}{}
+	}
+	return nil
+}
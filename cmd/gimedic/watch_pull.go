@@ -25,6 +25,7 @@ var watchPullCommand = &cobra.Command{
 		service := syncer.Service{
 			DBPath:     dbPath,
 			JournalDir: journalDir,
+			Warn:       func(msg string) { log.Warnf("watch-pull: %s", msg) },
 		}
 		journalPaths, err := service.ResolveJournalPaths(args)
 		if err != nil {
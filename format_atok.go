@@ -0,0 +1,88 @@
+package gimedic
+
+import (
+	"io"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// ATOKHeader is the first line of an ATOK dictionary utility text file.
+const ATOKHeader = "!!ATOK_TANGO_TEXT_HEADER_1"
+
+// atokParts maps ATOK POS names onto Parts.
+// The first name listed for a Part is the one written out.
+// Words without POS, suggestion-only words, the ハ行四段 and ラ変 verbs,
+// sentence-ending particles, punctuation and suppression words have no
+// ATOK equivalent.
+var atokParts = posTable{
+	{"名詞", PartNoun},
+	{"短縮読み", PartAbbreviation},
+	{"固有一般", PartProperNoun},
+	{"固有人他", PartPersonName},
+	{"固有人姓", PartSurname},
+	{"固有人名", PartGivenName},
+	{"固有組織", PartOrganization},
+	{"固有地名", PartPlaceName},
+	{"サ変名詞", PartSuruNoun},
+	{"形容動詞", PartAdjectivalNoun},
+	{"数詞", PartNumber},
+	{"英字", PartAlphabet},
+	{"記号", PartSymbol},
+	{"顔文字", PartEmoticon},
+	{"副詞", PartAdverb},
+	{"連体詞", PartAdnominal},
+	{"接続詞", PartConjunction},
+	{"感動詞", PartInterjection},
+	{"接頭語", PartPrefix},
+	{"助数詞", PartCounter},
+	{"接尾語", PartSuffixGeneral},
+	{"人名接尾", PartSuffixPersonName},
+	{"地名接尾", PartSuffixPlaceName},
+	{"ワ行五段", PartVerbGodanWaRow},
+	{"カ行五段", PartVerbGodanKaRow},
+	{"サ行五段", PartVerbGodanSaRow},
+	{"タ行五段", PartVerbGodanTaRow},
+	{"ナ行五段", PartVerbGodanNaRow},
+	{"マ行五段", PartVerbGodanMaRow},
+	{"ラ行五段", PartVerbGodanRaRow},
+	{"ガ行五段", PartVerbGodanGaRow},
+	{"バ行五段", PartVerbGodanBaRow},
+	{"一段動詞", PartVerbIchidan},
+	{"カ変動詞", PartVerbKahen},
+	{"サ変動詞", PartVerbSahen},
+	{"ザ変動詞", PartVerbZahen},
+	{"形容詞", PartAdjective},
+	{"独立語", PartFreeStandingWord},
+
+	// Names seen in exports of older ATOK versions; read only.
+	{"名詞サ変", PartSuruNoun},
+	{"形動名詞", PartAdjectivalNoun},
+	{"固有名詞", PartProperNoun},
+}
+
+// ATOKPart returns the Part for an ATOK POS name.
+// Mozc labels (see Part.String) are accepted as well.
+func ATOKPart(name string) (Part, bool) {
+	return atokParts.part(name)
+}
+
+// ATOKPOSName returns the ATOK POS name for the Part.
+func ATOKPOSName(part Part) (string, bool) {
+	return atokParts.name(part)
+}
+
+// ReadATOK reads an ATOK dictionary utility text file.
+// The text is UTF-16 or UTF-8 when it starts with a BOM and Shift_JIS
+// otherwise. Lines starting with '!' are headers. Entries whose POS has
+// no equivalent Part are returned as skipped.
+func ReadATOK(r io.Reader) ([]*UserDictionary_Entry, []Skipped, error) {
+	return readWordList(newTextReader(r, japanese.ShiftJIS), "!", atokParts)
+}
+
+// WriteATOK writes entries as an ATOK dictionary utility text file in
+// UTF-16LE with a BOM and CRLF line endings. Comments are not written.
+// Entries whose Part has no ATOK equivalent are left out and returned as
+// skipped.
+func WriteATOK(w io.Writer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+	return writeWordList(w, ATOKHeader+"\r\n", entries, atokParts, false)
+}
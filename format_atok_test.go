@@ -0,0 +1,66 @@
+package gimedic
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+func TestATOKPartsCoverage(t *testing.T) {
+	missing := map[Part]bool{
+		PartNone:                   true,
+		PartSuggestOnly:            true,
+		PartVerbYodanHaRow:         true,
+		PartVerbRahen:              true,
+		PartSentenceEndingParticle: true,
+		PartPunctuation:            true,
+		PartSuppressionWord:        true,
+	}
+	for i := range partNames {
+		part := Part(i)
+		name, ok := ATOKPOSName(part)
+		if ok == missing[part] {
+			t.Errorf("unexpected mapping for %s: %q %v", part, name, ok)
+		}
+	}
+}
+
+func TestWriteReadATOK(t *testing.T) {
+	entries := []*UserDictionary_Entry{
+		Record{Key: "やまだ", Value: "山田", Pos: PartSurname, Comment: "dropped"}.Entry(),
+		Record{Key: "たろう", Value: "太郎", Pos: PartGivenName}.Entry(),
+		Record{Key: "やまだたろう", Value: "山田太郎", Pos: PartPersonName}.Entry(),
+		Record{Key: "よ", Value: "よ", Pos: PartSentenceEndingParticle}.Entry(),
+	}
+	var buf bytes.Buffer
+	skipped, err := WriteATOK(&buf, entries)
+	if err != nil {
+		t.Fatalf("WriteATOK: %v", err)
+	}
+	if len(skipped) != 1 || skipped[0].Value != "よ" {
+		t.Fatalf("unexpected skipped: %v", skipped)
+	}
+	text, _, err := transform.Bytes(unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder(), buf.Bytes())
+	if err != nil {
+		t.Fatalf("decode UTF-16: %v", err)
+	}
+	want := ATOKHeader + "\r\nやまだ\t山田\t固有人姓\r\nたろう\t太郎\t固有人名\r\nやまだたろう\t山田太郎\t固有人他\r\n"
+	if string(text) != want {
+		t.Fatalf("unexpected output:\n%q", text)
+	}
+
+	read, skipped, err := ReadATOK(&buf)
+	if err != nil {
+		t.Fatalf("ReadATOK: %v", err)
+	}
+	if len(skipped) != 0 || len(read) != 3 {
+		t.Fatalf("unexpected result: %v %v", read, skipped)
+	}
+	for i, entry := range entries[:3] {
+		if read[i].GetPos() != entry.GetPos() {
+			t.Fatalf("pos of %s changed: %v -> %v", entry.GetValue(), entry.GetPos(), read[i].GetPos())
+		}
+	}
+}
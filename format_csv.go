@@ -0,0 +1,181 @@
+package gimedic
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteCSV writes the storage as RFC 4180 CSV with a header row.
+// A dictionary without entries is written as a row with only the
+// dictionary columns set.
+func WriteCSV(w io.Writer, storage *UserDictionaryStorage) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(RecordColumns); err != nil {
+		return err
+	}
+	for _, record := range flatRecords(storage) {
+		if err := writer.Write(record.fields()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteTSV writes the storage as tab-separated values with a header row,
+// keeping dictionaries without entries as WriteCSV does.
+// Backslash, tab, CR and LF in fields are escaped as \\, \t, \r and \n.
+func WriteTSV(w io.Writer, storage *UserDictionaryStorage) error {
+	writer := bufio.NewWriter(w)
+	if err := writeTSVRow(writer, RecordColumns); err != nil {
+		return err
+	}
+	for _, record := range flatRecords(storage) {
+		if err := writeTSVRow(writer, record.fields()); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+var tsvEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\t", `\t`,
+	"\r", `\r`,
+	"\n", `\n`,
+)
+
+func writeTSVRow(w *bufio.Writer, fields []string) error {
+	for i, field := range fields {
+		if i > 0 {
+			if err := w.WriteByte('\t'); err != nil {
+				return err
+			}
+		}
+		if _, err := tsvEscaper.WriteString(w, field); err != nil {
+			return err
+		}
+	}
+	return w.WriteByte('\n')
+}
+
+// ReadCSV reads CSV written by WriteCSV.
+// Columns are matched by the header row; only key and value are required.
+func ReadCSV(r io.Reader) (*UserDictionaryStorage, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("missing header row")
+		}
+		return nil, err
+	}
+	index, err := columnIndex(header)
+	if err != nil {
+		return nil, fmt.Errorf("line 1: %w", err)
+	}
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		record, err := recordFromColumns(index, row)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	return StorageFromRecords(records)
+}
+
+// ReadTSV reads TSV written by WriteTSV.
+// Columns are matched by the header row; only key and value are required.
+func ReadTSV(r io.Reader) (*UserDictionaryStorage, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var index map[string]int
+	var records []Record
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		fields, err := splitTSVRow(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if index == nil {
+			if index, err = columnIndex(fields); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			continue
+		}
+		record, err := recordFromColumns(index, fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if index == nil {
+		return nil, errors.New("missing header row")
+	}
+	return StorageFromRecords(records)
+}
+
+func splitTSVRow(text string) ([]string, error) {
+	fields := strings.Split(text, "\t")
+	for i, field := range fields {
+		unescaped, err := unescapeTSV(field)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %w", i+1, err)
+		}
+		fields[i] = unescaped
+	}
+	return fields, nil
+}
+
+func unescapeTSV(field string) (string, error) {
+	if !strings.Contains(field, `\`) {
+		return field, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(field) {
+			return "", errors.New("dangling backslash")
+		}
+		switch field[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			return "", fmt.Errorf("unknown escape \\%c", field[i])
+		}
+	}
+	return b.String(), nil
+}
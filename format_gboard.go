@@ -0,0 +1,110 @@
+package gimedic
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// GboardHeader is the first line of a Gboard personal dictionary.
+	GboardHeader = "# Gboard Dictionary version:1"
+	// GboardFileName is the name of the dictionary inside PersonalDictionary.zip.
+	GboardFileName = "dictionary.txt"
+)
+
+// ReadGboard reads a Gboard personal dictionary export. The input may be
+// the PersonalDictionary.zip archive or the dictionary.txt inside it.
+// Gboard has no POS, so every entry gets part; shortcuts are usually
+// imported as PartAbbreviation or PartNoun. Gboard also keeps words
+// without a shortcut, which Mozc cannot; those rows are returned as
+// skipped.
+func ReadGboard(r io.Reader, part Part) ([]*UserDictionary_Entry, []Skipped, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.HasPrefix(raw, []byte("PK\x03\x04")) {
+		if raw, err = readGboardArchive(raw); err != nil {
+			return nil, nil, err
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(raw, []byte("\ufeff"))))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var entries []*UserDictionary_Entry
+	var skipped []Skipped
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, nil, fmt.Errorf("line %d: expected 2 or 3 tab-separated fields, got %d", line, len(fields))
+		}
+		if fields[1] == "" {
+			return nil, nil, fmt.Errorf("line %d: empty word", line)
+		}
+		if fields[0] == "" {
+			skipped = append(skipped, Skipped{Line: line, Value: fields[1], Reason: "word without a shortcut"})
+			continue
+		}
+		record := Record{Key: fields[0], Value: fields[1], Pos: part}
+		if len(fields) == 3 {
+			record.Locale = fields[2]
+		}
+		entries = append(entries, record.Entry())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return entries, skipped, nil
+}
+
+func readGboardArchive(raw []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range archive.File {
+		if file.Name != GboardFileName {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, errors.New("no " + GboardFileName + " in archive")
+}
+
+// WriteGboard writes entries as a PersonalDictionary.zip archive that
+// Gboard can import. The POS and comment are dropped; the locale is kept.
+func WriteGboard(w io.Writer, entries []*UserDictionary_Entry) error {
+	archive := zip.NewWriter(w)
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: GboardFileName, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, GboardHeader)
+	for _, entry := range entries {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n",
+			singleLine.Replace(entry.GetKey()),
+			singleLine.Replace(entry.GetValue()),
+			singleLine.Replace(entry.GetLocale()),
+		)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return archive.Close()
+}
@@ -0,0 +1,71 @@
+package gimedic
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteReadGboard(t *testing.T) {
+	entries := []*UserDictionary_Entry{
+		Record{Key: "おつ", Value: "お疲れさまです", Pos: PartAbbreviation, Locale: "ja-JP"}.Entry(),
+		Record{Key: "gg", Value: "good game", Pos: PartNoun, Comment: "dropped"}.Entry(),
+	}
+	var buf bytes.Buffer
+	if err := WriteGboard(&buf, entries); err != nil {
+		t.Fatalf("WriteGboard: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	if len(archive.File) != 1 || archive.File[0].Name != GboardFileName {
+		t.Fatalf("unexpected archive: %v", archive.File)
+	}
+	rc, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatalf("open %s: %v", GboardFileName, err)
+	}
+	text, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("read %s: %v", GboardFileName, err)
+	}
+	want := GboardHeader + "\nおつ\tお疲れさまです\tja-JP\ngg\tgood game\t\n"
+	if string(text) != want {
+		t.Fatalf("unexpected dictionary.txt:\n%q", text)
+	}
+
+	read, _, err := ReadGboard(&buf, PartAbbreviation)
+	if err != nil {
+		t.Fatalf("ReadGboard: %v", err)
+	}
+	if len(read) != 2 {
+		t.Fatalf("unexpected entries: %v", read)
+	}
+	if read[0].GetLocale() != "ja-JP" || read[1].GetPos() != UserDictionary_ABBREVIATION {
+		t.Fatalf("unexpected entries: %v", read)
+	}
+}
+
+func TestReadGboardText(t *testing.T) {
+	entries, skipped, err := ReadGboard(strings.NewReader(GboardHeader+"\nおつ\tお疲れさまです\n\tGboard\tja-JP\n"), PartNoun)
+	if err != nil {
+		t.Fatalf("ReadGboard: %v", err)
+	}
+	if len(entries) != 1 || entries[0].GetPos() != UserDictionary_NOUN || entries[0].GetLocale() != "" {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	if len(skipped) != 1 || skipped[0].Line != 3 || skipped[0].Value != "Gboard" {
+		t.Fatalf("unexpected skipped: %v", skipped)
+	}
+	if _, _, err := ReadGboard(strings.NewReader("おつ\t\n"), PartNoun); err == nil || err.Error() != "line 1: empty word" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := ReadGboard(strings.NewReader("おつ\n"), PartNoun); err == nil || err.Error() != "line 1: expected 2 or 3 tab-separated fields, got 1" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
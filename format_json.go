@@ -0,0 +1,284 @@
+package gimedic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type storageJSON struct {
+	Version      int32            `json:"version"`
+	Dictionaries []dictionaryJSON `json:"dictionaries"`
+}
+
+type dictionaryJSON struct {
+	ID      uint64      `json:"id,string"`
+	Name    string      `json:"name"`
+	Entries []entryJSON `json:"entries"`
+}
+
+type entryJSON struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Pos     int32  `json:"pos"`
+	PosName string `json:"pos_name"`
+	Comment string `json:"comment"`
+	Locale  string `json:"locale"`
+}
+
+type recordJSON struct {
+	DictionaryID   uint64 `json:"dictionary_id,string"`
+	DictionaryName string `json:"dictionary"`
+	entryJSON
+}
+
+type dictionaryRecordJSON struct {
+	DictionaryID   uint64 `json:"dictionary_id,string"`
+	DictionaryName string `json:"dictionary"`
+}
+
+func newEntryJSON(key, value string, pos Part, comment, locale string) entryJSON {
+	return entryJSON{
+		Key:     key,
+		Value:   value,
+		Pos:     int32(pos),
+		PosName: pos.String(),
+		Comment: comment,
+		Locale:  locale,
+	}
+}
+
+func newStorageJSON(storage *UserDictionaryStorage) storageJSON {
+	doc := storageJSON{
+		Version:      storage.GetVersion(),
+		Dictionaries: []dictionaryJSON{},
+	}
+	for _, dict := range storage.GetDictionaries() {
+		d := dictionaryJSON{
+			ID:      dict.GetId(),
+			Name:    dict.GetName(),
+			Entries: []entryJSON{},
+		}
+		for _, entry := range dict.GetEntries() {
+			d.Entries = append(d.Entries, newEntryJSON(
+				entry.GetKey(),
+				entry.GetValue(),
+				Part(entry.GetPos()),
+				entry.GetComment(),
+				entry.GetLocale(),
+			))
+		}
+		doc.Dictionaries = append(doc.Dictionaries, d)
+	}
+	return doc
+}
+
+// WriteJSON writes the storage as a single indented JSON document.
+// Dictionary ids are written as strings to keep all 64 bits intact.
+func WriteJSON(w io.Writer, storage *UserDictionaryStorage) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(newStorageJSON(storage))
+}
+
+// WriteNDJSON writes one JSON object per entry, one per line.
+// A dictionary without entries is written as an object with only the
+// dictionary fields.
+func WriteNDJSON(w io.Writer, storage *UserDictionaryStorage) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, record := range flatRecords(storage) {
+		if record.dictionaryOnly() {
+			if err := encoder.Encode(dictionaryRecordJSON{
+				DictionaryID:   record.DictionaryID,
+				DictionaryName: record.DictionaryName,
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := encoder.Encode(recordJSON{
+			DictionaryID:   record.DictionaryID,
+			DictionaryName: record.DictionaryName,
+			entryJSON:      newEntryJSON(record.Key, record.Value, record.Pos, record.Comment, record.Locale),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type storageJSONInput struct {
+	Version      int32                 `json:"version"`
+	Dictionaries []dictionaryJSONInput `json:"dictionaries"`
+}
+
+type dictionaryJSONInput struct {
+	ID      jsonID           `json:"id"`
+	Name    string           `json:"name"`
+	Entries []entryJSONInput `json:"entries"`
+}
+
+type entryJSONInput struct {
+	Key     string          `json:"key"`
+	Value   string          `json:"value"`
+	Pos     json.RawMessage `json:"pos"`
+	PosName string          `json:"pos_name"`
+	Comment string          `json:"comment"`
+	Locale  string          `json:"locale"`
+}
+
+type recordJSONInput struct {
+	DictionaryID   jsonID `json:"dictionary_id"`
+	DictionaryName string `json:"dictionary"`
+	entryJSONInput
+}
+
+// jsonID accepts a dictionary id written either as a string or as a number.
+type jsonID uint64
+
+func (id *jsonID) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		if text == "" {
+			return nil
+		}
+	}
+	n, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid dictionary id %s", data)
+	}
+	*id = jsonID(n)
+	return nil
+}
+
+func (e entryJSONInput) record(dictID uint64, dictName string) (Record, error) {
+	if e.Key == "" {
+		return Record{}, errors.New("empty key")
+	}
+	if e.Value == "" {
+		return Record{}, errors.New("empty value")
+	}
+	pos := ""
+	if len(e.Pos) > 0 && string(e.Pos) != "null" {
+		if err := json.Unmarshal(e.Pos, &pos); err != nil {
+			pos = string(e.Pos)
+		}
+	}
+	part, err := resolvePart(pos, e.PosName)
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{
+		DictionaryID:   dictID,
+		DictionaryName: dictName,
+		Key:            e.Key,
+		Value:          e.Value,
+		Pos:            part,
+		Comment:        e.Comment,
+		Locale:         e.Locale,
+	}, nil
+}
+
+// ReadJSON reads a JSON document written by WriteJSON.
+// The pos field may hold either the numeric POS or its Japanese label.
+func ReadJSON(r io.Reader) (*UserDictionaryStorage, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	var doc storageJSONInput
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, jsonError(raw, err)
+	}
+	storage := &UserDictionaryStorage{}
+	if doc.Version != 0 {
+		version := doc.Version
+		storage.Version = &version
+	}
+	for i, d := range doc.Dictionaries {
+		name := d.Name
+		dict := &UserDictionary{Name: &name, Entries: []*UserDictionary_Entry{}}
+		if d.ID != 0 {
+			id := uint64(d.ID)
+			dict.Id = &id
+		}
+		for j, e := range d.Entries {
+			record, err := e.record(uint64(d.ID), d.Name)
+			if err != nil {
+				return nil, fmt.Errorf("dictionaries[%d].entries[%d]: %w", i, j, err)
+			}
+			dict.Entries = append(dict.Entries, record.Entry())
+		}
+		storage.Dictionaries = append(storage.Dictionaries, dict)
+	}
+	return storage, nil
+}
+
+// ReadNDJSON reads one JSON object per line as written by WriteNDJSON.
+func ReadNDJSON(r io.Reader) (*UserDictionaryStorage, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var records []Record
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		var input recordJSONInput
+		if err := decoder.Decode(&input); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if input.Key == "" && input.Value == "" && (input.DictionaryID != 0 || input.DictionaryName != "") {
+			records = append(records, Record{DictionaryID: uint64(input.DictionaryID), DictionaryName: input.DictionaryName})
+			continue
+		}
+		record, err := input.record(uint64(input.DictionaryID), input.DictionaryName)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return StorageFromRecords(records)
+}
+
+// jsonError prefixes decoding errors with the line they occurred on.
+func jsonError(raw []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+	if offset > int64(len(raw)) {
+		offset = int64(len(raw))
+	}
+	line := bytes.Count(raw[:offset], []byte{'\n'}) + 1
+	return fmt.Errorf("line %d: %w", line, err)
+}
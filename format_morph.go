@@ -0,0 +1,298 @@
+package gimedic
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MorphCost is the word cost written to morphological analyzer
+// dictionaries. It is the cost the Sudachi and MeCab documentation use
+// for user words.
+const MorphCost = 5000
+
+// morphPOS is a part of speech of a morphological analyzer dictionary:
+// four POS levels and the conjugation type.
+type morphPOS struct {
+	pos   [4]string
+	cType string
+}
+
+func (p morphPOS) conjugates() bool {
+	return p.cType != "*"
+}
+
+// ipadicParts maps Parts onto the IPADIC part of speech system.
+var ipadicParts = map[Part]morphPOS{
+	PartNone:                   {[4]string{"名詞", "一般", "*", "*"}, "*"},
+	PartNoun:                   {[4]string{"名詞", "一般", "*", "*"}, "*"},
+	PartAbbreviation:           {[4]string{"名詞", "一般", "*", "*"}, "*"},
+	PartSuggestOnly:            {[4]string{"名詞", "一般", "*", "*"}, "*"},
+	PartProperNoun:             {[4]string{"名詞", "固有名詞", "一般", "*"}, "*"},
+	PartPersonName:             {[4]string{"名詞", "固有名詞", "人名", "一般"}, "*"},
+	PartSurname:                {[4]string{"名詞", "固有名詞", "人名", "姓"}, "*"},
+	PartGivenName:              {[4]string{"名詞", "固有名詞", "人名", "名"}, "*"},
+	PartOrganization:           {[4]string{"名詞", "固有名詞", "組織", "*"}, "*"},
+	PartPlaceName:              {[4]string{"名詞", "固有名詞", "地域", "一般"}, "*"},
+	PartSuruNoun:               {[4]string{"名詞", "サ変接続", "*", "*"}, "*"},
+	PartAdjectivalNoun:         {[4]string{"名詞", "形容動詞語幹", "*", "*"}, "*"},
+	PartNumber:                 {[4]string{"名詞", "数", "*", "*"}, "*"},
+	PartAlphabet:               {[4]string{"記号", "アルファベット", "*", "*"}, "*"},
+	PartSymbol:                 {[4]string{"記号", "一般", "*", "*"}, "*"},
+	PartEmoticon:               {[4]string{"記号", "一般", "*", "*"}, "*"},
+	PartAdverb:                 {[4]string{"副詞", "一般", "*", "*"}, "*"},
+	PartAdnominal:              {[4]string{"連体詞", "*", "*", "*"}, "*"},
+	PartConjunction:            {[4]string{"接続詞", "*", "*", "*"}, "*"},
+	PartInterjection:           {[4]string{"感動詞", "*", "*", "*"}, "*"},
+	PartPrefix:                 {[4]string{"接頭詞", "名詞接続", "*", "*"}, "*"},
+	PartCounter:                {[4]string{"名詞", "接尾", "助数詞", "*"}, "*"},
+	PartSuffixGeneral:          {[4]string{"名詞", "接尾", "一般", "*"}, "*"},
+	PartSuffixPersonName:       {[4]string{"名詞", "接尾", "人名", "*"}, "*"},
+	PartSuffixPlaceName:        {[4]string{"名詞", "接尾", "地域", "*"}, "*"},
+	PartVerbGodanWaRow:         {[4]string{"動詞", "自立", "*", "*"}, "五段・ワ行促音便"},
+	PartVerbGodanKaRow:         {[4]string{"動詞", "自立", "*", "*"}, "五段・カ行イ音便"},
+	PartVerbGodanSaRow:         {[4]string{"動詞", "自立", "*", "*"}, "五段・サ行"},
+	PartVerbGodanTaRow:         {[4]string{"動詞", "自立", "*", "*"}, "五段・タ行"},
+	PartVerbGodanNaRow:         {[4]string{"動詞", "自立", "*", "*"}, "五段・ナ行"},
+	PartVerbGodanMaRow:         {[4]string{"動詞", "自立", "*", "*"}, "五段・マ行"},
+	PartVerbGodanRaRow:         {[4]string{"動詞", "自立", "*", "*"}, "五段・ラ行"},
+	PartVerbGodanGaRow:         {[4]string{"動詞", "自立", "*", "*"}, "五段・ガ行"},
+	PartVerbGodanBaRow:         {[4]string{"動詞", "自立", "*", "*"}, "五段・バ行"},
+	PartVerbYodanHaRow:         {[4]string{"動詞", "自立", "*", "*"}, "四段・ハ行"},
+	PartVerbIchidan:            {[4]string{"動詞", "自立", "*", "*"}, "一段"},
+	PartVerbKahen:              {[4]string{"動詞", "自立", "*", "*"}, "カ変・クル"},
+	PartVerbSahen:              {[4]string{"動詞", "自立", "*", "*"}, "サ変・スル"},
+	PartVerbZahen:              {[4]string{"動詞", "自立", "*", "*"}, "サ変・－ズル"},
+	PartVerbRahen:              {[4]string{"動詞", "自立", "*", "*"}, "ラ変"},
+	PartAdjective:              {[4]string{"形容詞", "自立", "*", "*"}, "形容詞・アウオ段"},
+	PartSentenceEndingParticle: {[4]string{"助詞", "終助詞", "*", "*"}, "*"},
+	PartPunctuation:            {[4]string{"記号", "読点", "*", "*"}, "*"},
+	PartFreeStandingWord:       {[4]string{"感動詞", "*", "*", "*"}, "*"},
+}
+
+// unidicParts maps Parts onto the UniDic part of speech system, which
+// Sudachi uses as well. The conjugation type of 一段 verbs depends on the
+// reading and is filled in by unidicPOS.
+var unidicParts = map[Part]morphPOS{
+	PartNone:                   {[4]string{"名詞", "普通名詞", "一般", "*"}, "*"},
+	PartNoun:                   {[4]string{"名詞", "普通名詞", "一般", "*"}, "*"},
+	PartAbbreviation:           {[4]string{"名詞", "普通名詞", "一般", "*"}, "*"},
+	PartSuggestOnly:            {[4]string{"名詞", "普通名詞", "一般", "*"}, "*"},
+	PartProperNoun:             {[4]string{"名詞", "固有名詞", "一般", "*"}, "*"},
+	PartPersonName:             {[4]string{"名詞", "固有名詞", "人名", "一般"}, "*"},
+	PartSurname:                {[4]string{"名詞", "固有名詞", "人名", "姓"}, "*"},
+	PartGivenName:              {[4]string{"名詞", "固有名詞", "人名", "名"}, "*"},
+	PartOrganization:           {[4]string{"名詞", "固有名詞", "一般", "*"}, "*"},
+	PartPlaceName:              {[4]string{"名詞", "固有名詞", "地名", "一般"}, "*"},
+	PartSuruNoun:               {[4]string{"名詞", "普通名詞", "サ変可能", "*"}, "*"},
+	PartAdjectivalNoun:         {[4]string{"名詞", "普通名詞", "形状詞可能", "*"}, "*"},
+	PartNumber:                 {[4]string{"名詞", "数詞", "*", "*"}, "*"},
+	PartAlphabet:               {[4]string{"記号", "文字", "*", "*"}, "*"},
+	PartSymbol:                 {[4]string{"記号", "一般", "*", "*"}, "*"},
+	PartEmoticon:               {[4]string{"補助記号", "ＡＡ", "顔文字", "*"}, "*"},
+	PartAdverb:                 {[4]string{"副詞", "*", "*", "*"}, "*"},
+	PartAdnominal:              {[4]string{"連体詞", "*", "*", "*"}, "*"},
+	PartConjunction:            {[4]string{"接続詞", "*", "*", "*"}, "*"},
+	PartInterjection:           {[4]string{"感動詞", "一般", "*", "*"}, "*"},
+	PartPrefix:                 {[4]string{"接頭辞", "*", "*", "*"}, "*"},
+	PartCounter:                {[4]string{"接尾辞", "名詞的", "助数詞", "*"}, "*"},
+	PartSuffixGeneral:          {[4]string{"接尾辞", "名詞的", "一般", "*"}, "*"},
+	PartSuffixPersonName:       {[4]string{"接尾辞", "名詞的", "一般", "*"}, "*"},
+	PartSuffixPlaceName:        {[4]string{"接尾辞", "名詞的", "一般", "*"}, "*"},
+	PartVerbGodanWaRow:         {[4]string{"動詞", "一般", "*", "*"}, "五段-ワア行"},
+	PartVerbGodanKaRow:         {[4]string{"動詞", "一般", "*", "*"}, "五段-カ行"},
+	PartVerbGodanSaRow:         {[4]string{"動詞", "一般", "*", "*"}, "五段-サ行"},
+	PartVerbGodanTaRow:         {[4]string{"動詞", "一般", "*", "*"}, "五段-タ行"},
+	PartVerbGodanNaRow:         {[4]string{"動詞", "一般", "*", "*"}, "五段-ナ行"},
+	PartVerbGodanMaRow:         {[4]string{"動詞", "一般", "*", "*"}, "五段-マ行"},
+	PartVerbGodanRaRow:         {[4]string{"動詞", "一般", "*", "*"}, "五段-ラ行"},
+	PartVerbGodanGaRow:         {[4]string{"動詞", "一般", "*", "*"}, "五段-ガ行"},
+	PartVerbGodanBaRow:         {[4]string{"動詞", "一般", "*", "*"}, "五段-バ行"},
+	PartVerbYodanHaRow:         {[4]string{"動詞", "一般", "*", "*"}, "文語四段-ハ行"},
+	PartVerbIchidan:            {[4]string{"動詞", "一般", "*", "*"}, "下一段-ア行"},
+	PartVerbKahen:              {[4]string{"動詞", "一般", "*", "*"}, "カ行変格"},
+	PartVerbSahen:              {[4]string{"動詞", "一般", "*", "*"}, "サ行変格"},
+	PartVerbZahen:              {[4]string{"動詞", "一般", "*", "*"}, "サ行変格"},
+	PartVerbRahen:              {[4]string{"動詞", "一般", "*", "*"}, "文語ラ行変格"},
+	PartAdjective:              {[4]string{"形容詞", "一般", "*", "*"}, "形容詞"},
+	PartSentenceEndingParticle: {[4]string{"助詞", "終助詞", "*", "*"}, "*"},
+	PartPunctuation:            {[4]string{"補助記号", "読点", "*", "*"}, "*"},
+	PartFreeStandingWord:       {[4]string{"感動詞", "一般", "*", "*"}, "*"},
+}
+
+// ichidanRows maps the kana before the final る of a 一段 verb onto its
+// UniDic conjugation type.
+var ichidanRows = map[string]string{
+	"い": "上一段-ア行", "き": "上一段-カ行", "ぎ": "上一段-ガ行", "し": "上一段-サ行",
+	"じ": "上一段-ザ行", "ち": "上一段-タ行", "ぢ": "上一段-ダ行", "に": "上一段-ナ行",
+	"ひ": "上一段-ハ行", "び": "上一段-バ行", "ぴ": "上一段-パ行", "み": "上一段-マ行",
+	"り": "上一段-ラ行",
+	"え": "下一段-ア行", "け": "下一段-カ行", "げ": "下一段-ガ行", "せ": "下一段-サ行",
+	"ぜ": "下一段-ザ行", "て": "下一段-タ行", "で": "下一段-ダ行", "ね": "下一段-ナ行",
+	"へ": "下一段-ハ行", "べ": "下一段-バ行", "ぺ": "下一段-パ行", "め": "下一段-マ行",
+	"れ": "下一段-ラ行",
+}
+
+func ipadicPOS(entry *UserDictionary_Entry) morphPOS {
+	pos := ipadicParts[Part(entry.GetPos())]
+	if Part(entry.GetPos()) == PartAdjective && strings.HasSuffix(entry.GetKey(), "しい") {
+		pos.cType = "形容詞・イ段"
+	}
+	return pos
+}
+
+func unidicPOS(entry *UserDictionary_Entry) morphPOS {
+	pos := unidicParts[Part(entry.GetPos())]
+	if Part(entry.GetPos()) == PartVerbIchidan {
+		stem := strings.TrimSuffix(entry.GetKey(), "る")
+		if _, size := utf8.DecodeLastRuneInString(stem); size > 0 {
+			if cType, ok := ichidanRows[stem[len(stem)-size:]]; ok {
+				pos.cType = cType
+			}
+		}
+	}
+	return pos
+}
+
+// sudachiConnectionIDs are the connection ids of the Sudachi core system
+// dictionary for the parts of speech user words are most often
+// registered as. Entries of other parts of speech are skipped rather than
+// written with an id that does not connect like their POS.
+var sudachiConnectionIDs = map[[4]string]int{
+	{"名詞", "普通名詞", "一般", "*"}: 5146,
+	{"名詞", "固有名詞", "一般", "*"}: 4786,
+}
+
+// KatakanaReading converts the hiragana in a reading into katakana, the
+// form morphological analyzers expect.
+func KatakanaReading(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ぁ' && r <= 'ゖ' {
+			return r + ('ァ' - 'ぁ')
+		}
+		return r
+	}, key)
+}
+
+// writeMorphCSV writes entries as CSV rows built by row. Suppression
+// words and entries with an unknown POS are left out and returned as
+// skipped since they do not describe words, as are entries row gives a
+// skip reason for.
+func writeMorphCSV(w io.Writer, entries []*UserDictionary_Entry, row func(*UserDictionary_Entry) ([]string, string)) ([]Skipped, error) {
+	writer := csv.NewWriter(w)
+	var skipped []Skipped
+	for _, entry := range entries {
+		part := Part(entry.GetPos())
+		if _, ok := ipadicParts[part]; !ok {
+			skipped = append(skipped, Skipped{Key: entry.GetKey(), Value: entry.GetValue(), Pos: part.String(), Reason: "not a word"})
+			continue
+		}
+		record, reason := row(entry)
+		if reason != "" {
+			skipped = append(skipped, Skipped{Key: entry.GetKey(), Value: entry.GetValue(), Pos: part.String(), Reason: reason})
+			continue
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return skipped, nil
+}
+
+func morphCForm(pos morphPOS, form string) string {
+	if pos.conjugates() {
+		return form
+	}
+	return "*"
+}
+
+// WriteMeCabIPADIC writes entries as a MeCab user dictionary CSV for the
+// IPADIC system dictionary. Context ids are left empty for
+// mecab-dict-index to assign. Verbs and adjectives are written in their
+// base form only, without rows for the inflected forms. Suppression
+// words are left out and returned as skipped.
+func WriteMeCabIPADIC(w io.Writer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+	return writeMorphCSV(w, entries, func(entry *UserDictionary_Entry) ([]string, string) {
+		pos := ipadicPOS(entry)
+		reading := KatakanaReading(entry.GetKey())
+		return []string{
+			entry.GetValue(), "", "", strconv.Itoa(MorphCost),
+			pos.pos[0], pos.pos[1], pos.pos[2], pos.pos[3],
+			pos.cType, morphCForm(pos, "基本形"),
+			entry.GetValue(), reading, reading,
+		}, ""
+	})
+}
+
+// WriteMeCabUniDic writes entries as a MeCab user dictionary CSV for the
+// UniDic system dictionary. Context ids are left empty for
+// mecab-dict-index to assign. Verbs and adjectives are written in their
+// base form only, without rows for the inflected forms. Suppression
+// words are left out and returned as skipped.
+func WriteMeCabUniDic(w io.Writer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+	return writeMorphCSV(w, entries, func(entry *UserDictionary_Entry) ([]string, string) {
+		pos := unidicPOS(entry)
+		reading := KatakanaReading(entry.GetKey())
+		return []string{
+			entry.GetValue(), "", "", strconv.Itoa(MorphCost),
+			pos.pos[0], pos.pos[1], pos.pos[2], pos.pos[3],
+			pos.cType, morphCForm(pos, "終止形-一般"),
+			reading, entry.GetValue(), entry.GetValue(), reading,
+			entry.GetValue(), reading,
+			"*", "*", "*", "*", "*",
+		}, ""
+	})
+}
+
+// WriteSudachi writes entries as a Sudachi user dictionary source CSV.
+// Only parts of speech listed in sudachiConnectionIDs can be written;
+// other entries and suppression words are left out and returned as
+// skipped.
+func WriteSudachi(w io.Writer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+	return writeMorphCSV(w, entries, func(entry *UserDictionary_Entry) ([]string, string) {
+		pos := unidicPOS(entry)
+		id, ok := sudachiConnectionIDs[pos.pos]
+		if !ok {
+			return nil, "no Sudachi connection id for " + strings.Join(pos.pos[:], ",")
+		}
+		return []string{
+			strings.ToLower(norm.NFKC.String(entry.GetValue())),
+			strconv.Itoa(id), strconv.Itoa(id), strconv.Itoa(MorphCost),
+			entry.GetValue(),
+			pos.pos[0], pos.pos[1], pos.pos[2], pos.pos[3],
+			pos.cType, morphCForm(pos, "終止形-一般"),
+			KatakanaReading(entry.GetKey()), entry.GetValue(),
+			"*", "A", "*", "*", "*",
+		}, ""
+	})
+}
+
+// WriteKuromoji writes entries as a Kuromoji user dictionary CSV:
+// surface, segmentation, reading and a hyphen-joined IPADIC POS. Words
+// are not segmented and verbs and adjectives are written in their base
+// form only. Suppression words are left out and returned as skipped.
+func WriteKuromoji(w io.Writer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+	return writeMorphCSV(w, entries, func(entry *UserDictionary_Entry) ([]string, string) {
+		pos := ipadicPOS(entry)
+		levels := make([]string, 0, len(pos.pos))
+		for _, level := range pos.pos {
+			if level != "*" {
+				levels = append(levels, level)
+			}
+		}
+		return []string{
+			entry.GetValue(),
+			entry.GetValue(),
+			KatakanaReading(entry.GetKey()),
+			strings.Join(levels, "-"),
+		}, ""
+	})
+}
@@ -0,0 +1,95 @@
+package gimedic
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func morphEntries() []*UserDictionary_Entry {
+	return []*UserDictionary_Entry{
+		Record{Key: "ぐーぐる", Value: "Google", Pos: PartOrganization}.Entry(),
+		Record{Key: "たべる", Value: "食べる", Pos: PartVerbIchidan}.Entry(),
+		Record{Key: "うつくしい", Value: "美しい", Pos: PartAdjective}.Entry(),
+		Record{Key: "かんま", Value: "a,b", Pos: PartNoun}.Entry(),
+		Record{Key: "ばか", Value: "馬鹿", Pos: PartSuppressionWord}.Entry(),
+	}
+}
+
+func TestKatakanaReading(t *testing.T) {
+	if got := KatakanaReading("ぐーぐるゔぁx"); got != "グーグルヴァx" {
+		t.Fatalf("unexpected reading: %q", got)
+	}
+}
+
+func TestWriteMorph(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(*bytes.Buffer, []*UserDictionary_Entry) ([]Skipped, error)
+		want    string
+		skipped []string
+	}{
+		{
+			name: "mecab-ipadic",
+			write: func(buf *bytes.Buffer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+				return WriteMeCabIPADIC(buf, entries)
+			},
+			want: "Google,,,5000,名詞,固有名詞,組織,*,*,*,Google,グーグル,グーグル\n" +
+				"食べる,,,5000,動詞,自立,*,*,一段,基本形,食べる,タベル,タベル\n" +
+				"美しい,,,5000,形容詞,自立,*,*,形容詞・イ段,基本形,美しい,ウツクシイ,ウツクシイ\n" +
+				"\"a,b\",,,5000,名詞,一般,*,*,*,*,\"a,b\",カンマ,カンマ\n",
+		},
+		{
+			name: "mecab-unidic",
+			write: func(buf *bytes.Buffer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+				return WriteMeCabUniDic(buf, entries)
+			},
+			want: "Google,,,5000,名詞,固有名詞,一般,*,*,*,グーグル,Google,Google,グーグル,Google,グーグル,*,*,*,*,*\n" +
+				"食べる,,,5000,動詞,一般,*,*,下一段-バ行,終止形-一般,タベル,食べる,食べる,タベル,食べる,タベル,*,*,*,*,*\n" +
+				"美しい,,,5000,形容詞,一般,*,*,形容詞,終止形-一般,ウツクシイ,美しい,美しい,ウツクシイ,美しい,ウツクシイ,*,*,*,*,*\n" +
+				"\"a,b\",,,5000,名詞,普通名詞,一般,*,*,*,カンマ,\"a,b\",\"a,b\",カンマ,\"a,b\",カンマ,*,*,*,*,*\n",
+		},
+		{
+			name: "sudachi",
+			write: func(buf *bytes.Buffer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+				return WriteSudachi(buf, entries)
+			},
+			want: "google,4786,4786,5000,Google,名詞,固有名詞,一般,*,*,*,グーグル,Google,*,A,*,*,*\n" +
+				"\"a,b\",5146,5146,5000,\"a,b\",名詞,普通名詞,一般,*,*,*,カンマ,\"a,b\",*,A,*,*,*\n",
+			skipped: []string{"食べる", "美しい", "馬鹿"},
+		},
+		{
+			name: "kuromoji",
+			write: func(buf *bytes.Buffer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+				return WriteKuromoji(buf, entries)
+			},
+			want: "Google,Google,グーグル,名詞-固有名詞-組織\n" +
+				"食べる,食べる,タベル,動詞-自立\n" +
+				"美しい,美しい,ウツクシイ,形容詞-自立\n" +
+				"\"a,b\",\"a,b\",カンマ,名詞-一般\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			skipped, err := tt.write(&buf, morphEntries())
+			if err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Fatalf("unexpected output:\n%s", buf.String())
+			}
+			want := tt.skipped
+			if want == nil {
+				want = []string{"馬鹿"}
+			}
+			var got []string
+			for _, s := range skipped {
+				got = append(got, s.Value)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("unexpected skipped: %v", skipped)
+			}
+		})
+	}
+}
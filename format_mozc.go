@@ -0,0 +1,66 @@
+package gimedic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// ReadMozcTSV reads the text format of the Google IME dictionary tool:
+// one "reading<TAB>word<TAB>POS[<TAB>comment]" line per entry with the
+// Japanese POS labels. The text is UTF-8 unless a UTF-16 BOM says
+// otherwise; CRLF line endings, blank lines and lines starting with '#'
+// are accepted.
+func ReadMozcTSV(r io.Reader) ([]*UserDictionary_Entry, error) {
+	scanner := bufio.NewScanner(newTextReader(r, unicode.UTF8))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var entries []*UserDictionary_Entry
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, fmt.Errorf("line %d: expected 3 or 4 tab-separated fields, got %d", line, len(fields))
+		}
+		if fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("line %d: empty reading or word", line)
+		}
+		part, ok := ParsePart(fields[2])
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown POS %q", line, fields[2])
+		}
+		record := Record{Key: fields[0], Value: fields[1], Pos: part}
+		if len(fields) == 4 {
+			record.Comment = fields[3]
+		}
+		entries = append(entries, record.Entry())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WriteMozcTSV writes entries in the text format of the Google IME dictionary tool.
+// The format has no escaping, so tabs and line breaks in fields become spaces.
+func WriteMozcTSV(w io.Writer, entries []*UserDictionary_Entry) error {
+	writer := bufio.NewWriter(w)
+	for _, entry := range entries {
+		if _, err := fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n",
+			singleLine.Replace(entry.GetKey()),
+			singleLine.Replace(entry.GetValue()),
+			Part(entry.GetPos()).String(),
+			singleLine.Replace(entry.GetComment()),
+		); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,62 @@
+package gimedic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadMozcTSV(t *testing.T) {
+	input := "\ufeff# Google IME export\r\n" +
+		"ぐーぐる\tGoogle\t組織\tsearch\r\n" +
+		"\r\n" +
+		"かお\t(^_^)\t顔文字\r\n"
+	entries, err := ReadMozcTSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadMozcTSV: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("unexpected entries: %d", len(entries))
+	}
+	if entries[0].GetKey() != "ぐーぐる" || entries[0].GetPos() != UserDictionary_ORGANIZATION_NAME || entries[0].GetComment() != "search" {
+		t.Fatalf("unexpected entry: %v", entries[0])
+	}
+	if entries[1].GetValue() != "(^_^)" || entries[1].GetPos() != UserDictionary_EMOTICON || entries[1].GetComment() != "" {
+		t.Fatalf("unexpected entry: %v", entries[1])
+	}
+}
+
+func TestReadMozcTSVErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{"あ\t亜\n", "line 1: expected 3 or 4 tab-separated fields, got 2"},
+		{"# c\nあ\t亜\t品詞\n", `line 2: unknown POS "品詞"`},
+		{"\t亜\t名詞\n", "line 1: empty reading or word"},
+	} {
+		_, err := ReadMozcTSV(strings.NewReader(tc.input))
+		if err == nil || err.Error() != tc.want {
+			t.Fatalf("unexpected error for %q: %v", tc.input, err)
+		}
+	}
+}
+
+func TestWriteMozcTSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMozcTSV(&buf, sampleStorage().GetDictionaries()[0].GetEntries()); err != nil {
+		t.Fatalf("WriteMozcTSV: %v", err)
+	}
+	want := "ぐーぐる\tGoogle\t組織\tline1 line2 \"quoted\", \\\n" +
+		"かお\t(^_^)\t顔文字\t\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	entries, err := ReadMozcTSV(&buf)
+	if err != nil {
+		t.Fatalf("ReadMozcTSV: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("unexpected entries: %d", len(entries))
+	}
+}
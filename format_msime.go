@@ -0,0 +1,87 @@
+package gimedic
+
+import (
+	"io"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// MSIMEHeader is the first line of a Microsoft IME dictionary tool text file.
+const MSIMEHeader = "!Microsoft IME Dictionary Tool"
+
+// msimeParts maps Microsoft IME POS names onto Parts.
+// The first name listed for a Part is the one written out.
+var msimeParts = posTable{
+	{"名詞", PartNoun},
+	{"短縮よみ", PartAbbreviation},
+	{"固有名詞", PartProperNoun},
+	{"人名", PartPersonName},
+	{"姓", PartSurname},
+	{"名", PartGivenName},
+	{"組織名", PartOrganization},
+	{"地名", PartPlaceName},
+	{"さ変名詞", PartSuruNoun},
+	{"形容動詞", PartAdjectivalNoun},
+	{"記号", PartSymbol},
+	{"顔文字", PartEmoticon},
+	{"副詞", PartAdverb},
+	{"連体詞", PartAdnominal},
+	{"接続詞", PartConjunction},
+	{"感動詞", PartInterjection},
+	{"接頭語", PartPrefix},
+	{"助数詞", PartCounter},
+	{"接尾語", PartSuffixGeneral},
+	{"ワ行五段", PartVerbGodanWaRow},
+	{"カ行五段", PartVerbGodanKaRow},
+	{"サ行五段", PartVerbGodanSaRow},
+	{"タ行五段", PartVerbGodanTaRow},
+	{"ナ行五段", PartVerbGodanNaRow},
+	{"マ行五段", PartVerbGodanMaRow},
+	{"ラ行五段", PartVerbGodanRaRow},
+	{"ガ行五段", PartVerbGodanGaRow},
+	{"バ行五段", PartVerbGodanBaRow},
+	{"一段動詞", PartVerbIchidan},
+	{"カ行変格", PartVerbKahen},
+	{"サ行変格", PartVerbSahen},
+	{"ザ行変格", PartVerbZahen},
+	{"ラ行変格", PartVerbRahen},
+	{"形容詞", PartAdjective},
+	{"独立語", PartFreeStandingWord},
+
+	// Names seen in exports of other IME versions; read only.
+	{"サ変名詞", PartSuruNoun},
+	{"形動名詞", PartAdjectivalNoun},
+	{"組織", PartOrganization},
+	{"人名その他", PartPersonName},
+	{"地名その他", PartPlaceName},
+	{"接尾一般", PartSuffixGeneral},
+	{"接尾人名", PartSuffixPersonName},
+	{"接尾地名", PartSuffixPlaceName},
+}
+
+// MSIMEPart returns the Part for a Microsoft IME POS name.
+// Mozc labels (see Part.String) are accepted as well.
+func MSIMEPart(name string) (Part, bool) {
+	return msimeParts.part(name)
+}
+
+// MSIMEPOSName returns the Microsoft IME POS name for the Part.
+func MSIMEPOSName(part Part) (string, bool) {
+	return msimeParts.name(part)
+}
+
+// ReadMSIME reads a Microsoft IME dictionary tool text file.
+// The text is UTF-16 or UTF-8 when it starts with a BOM and Shift_JIS
+// otherwise. Lines starting with '!' are headers. Entries whose POS has
+// no equivalent Part are returned as skipped.
+func ReadMSIME(r io.Reader) ([]*UserDictionary_Entry, []Skipped, error) {
+	return readWordList(newTextReader(r, japanese.ShiftJIS), "!", msimeParts)
+}
+
+// WriteMSIME writes entries as a Microsoft IME dictionary tool text file
+// in UTF-16LE with a BOM and CRLF line endings. Entries whose Part has no
+// Microsoft IME equivalent are left out and returned as skipped.
+func WriteMSIME(w io.Writer, entries []*UserDictionary_Entry) ([]Skipped, error) {
+	header := MSIMEHeader + "\r\n!Version:\r\n!Format:WORDLIST\r\n\r\n"
+	return writeWordList(w, header, entries, msimeParts, true)
+}
@@ -0,0 +1,70 @@
+package gimedic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+func TestWriteMSIME(t *testing.T) {
+	entries := []*UserDictionary_Entry{
+		Record{Key: "ぐーぐる", Value: "Google", Pos: PartOrganization, Comment: "search"}.Entry(),
+		Record{Key: "ごだん", Value: "五段", Pos: PartVerbGodanKaRow}.Entry(),
+		Record{Key: "よ", Value: "よ", Pos: PartSentenceEndingParticle}.Entry(),
+	}
+	var buf bytes.Buffer
+	skipped, err := WriteMSIME(&buf, entries)
+	if err != nil {
+		t.Fatalf("WriteMSIME: %v", err)
+	}
+	if len(skipped) != 1 || skipped[0].Key != "よ" || skipped[0].Pos != "終助詞" {
+		t.Fatalf("unexpected skipped: %v", skipped)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte{0xff, 0xfe, '!', 0}) {
+		t.Fatalf("expected UTF-16LE BOM: % x", buf.Bytes()[:4])
+	}
+
+	read, skipped, err := ReadMSIME(&buf)
+	if err != nil {
+		t.Fatalf("ReadMSIME: %v", err)
+	}
+	if len(skipped) != 0 {
+		t.Fatalf("unexpected skipped: %v", skipped)
+	}
+	if len(read) != 2 {
+		t.Fatalf("unexpected entries: %v", read)
+	}
+	if read[0].GetPos() != UserDictionary_ORGANIZATION_NAME || read[0].GetComment() != "search" {
+		t.Fatalf("unexpected entry: %v", read[0])
+	}
+	if read[1].GetPos() != UserDictionary_KA_GROUP1_VERB {
+		t.Fatalf("unexpected entry: %v", read[1])
+	}
+}
+
+func TestReadMSIMEShiftJIS(t *testing.T) {
+	text := MSIMEHeader + "\r\n!Format:WORDLIST\r\n\r\n" +
+		"やまだ\t山田\t姓\r\n" +
+		"さへん\tサ変\tサ変名詞\r\n" +
+		"かん\t漢\t単漢字\r\n"
+	raw, _, err := transform.Bytes(japanese.ShiftJIS.NewEncoder(), []byte(text))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	entries, skipped, err := ReadMSIME(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMSIME: %v", err)
+	}
+	if len(entries) != 2 || entries[0].GetPos() != UserDictionary_FAMILY_NAME || entries[1].GetPos() != UserDictionary_SA_IRREGULAR_CONJUGATION_NOUN {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	if len(skipped) != 1 || skipped[0].Line != 6 || skipped[0].Pos != "単漢字" {
+		t.Fatalf("unexpected skipped: %v", skipped)
+	}
+	if !strings.Contains(skipped[0].String(), "line 6") {
+		t.Fatalf("unexpected report: %s", skipped[0])
+	}
+}
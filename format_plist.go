@@ -0,0 +1,102 @@
+package gimedic
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const plistHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+`
+
+// ReadPlist reads the property list macOS uses to import and export text
+// replacements: an array of dicts with "shortcut" and "phrase" strings.
+// The property list has no POS, so every entry gets part.
+func ReadPlist(r io.Reader, part Part) ([]*UserDictionary_Entry, error) {
+	decoder := xml.NewDecoder(r)
+	var entries []*UserDictionary_Entry
+	var path []string
+	var key string
+	var item map[string]string
+	seenPlist := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			switch strings.Join(path, "/") {
+			case "plist":
+				seenPlist = true
+			case "plist/array/dict":
+				item = map[string]string{}
+			case "plist/array/dict/key", "plist/array/dict/string":
+				var text string
+				if err := decoder.DecodeElement(&text, &t); err != nil {
+					return nil, err
+				}
+				path = path[:len(path)-1]
+				if t.Name.Local == "key" {
+					key = text
+				} else if key != "" {
+					item[key] = text
+					key = ""
+				}
+			default:
+				if len(path) == 4 {
+					key = ""
+				}
+			}
+		case xml.EndElement:
+			if strings.Join(path, "/") == "plist/array/dict" {
+				line, _ := decoder.InputPos()
+				if item["shortcut"] == "" || item["phrase"] == "" {
+					return nil, fmt.Errorf("line %d: text replacement without shortcut or phrase", line)
+				}
+				entries = append(entries, Record{Key: item["shortcut"], Value: item["phrase"], Pos: part}.Entry())
+			}
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+	if !seenPlist {
+		return nil, errors.New("not a property list")
+	}
+	return entries, nil
+}
+
+// WritePlist writes entries as a macOS text replacement property list.
+// Only the key and value are kept, as shortcut and phrase.
+func WritePlist(w io.Writer, entries []*UserDictionary_Entry) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprint(writer, plistHeader)
+	if len(entries) == 0 {
+		fmt.Fprint(writer, "<array/>\n</plist>\n")
+		return writer.Flush()
+	}
+	fmt.Fprint(writer, "<array>\n")
+	for _, entry := range entries {
+		fmt.Fprint(writer, "\t<dict>\n\t\t<key>phrase</key>\n\t\t<string>")
+		if err := xml.EscapeText(writer, []byte(entry.GetValue())); err != nil {
+			return err
+		}
+		fmt.Fprint(writer, "</string>\n\t\t<key>shortcut</key>\n\t\t<string>")
+		if err := xml.EscapeText(writer, []byte(entry.GetKey())); err != nil {
+			return err
+		}
+		fmt.Fprint(writer, "</string>\n\t</dict>\n")
+	}
+	fmt.Fprint(writer, "</array>\n</plist>\n")
+	return writer.Flush()
+}
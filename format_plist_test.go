@@ -0,0 +1,58 @@
+package gimedic
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPlist(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "text_substitutions.plist"))
+	if err != nil {
+		t.Fatalf("open golden: %v", err)
+	}
+	defer file.Close()
+	entries, err := ReadPlist(file, PartAbbreviation)
+	if err != nil {
+		t.Fatalf("ReadPlist: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	if entries[0].GetKey() != "おつ" || entries[0].GetValue() != "お疲れさまです" || entries[0].GetPos() != UserDictionary_ABBREVIATION {
+		t.Fatalf("unexpected entry: %v", entries[0])
+	}
+	if entries[1].GetValue() != "Tom & Jerry <3" {
+		t.Fatalf("unexpected entry: %v", entries[1])
+	}
+}
+
+func TestWritePlistGolden(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "text_substitutions.plist"))
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	entries := []*UserDictionary_Entry{
+		Record{Key: "おつ", Value: "お疲れさまです", Pos: PartAbbreviation}.Entry(),
+		Record{Key: "tj", Value: "Tom & Jerry <3", Pos: PartNoun, Comment: "dropped"}.Entry(),
+	}
+	var buf bytes.Buffer
+	if err := WritePlist(&buf, entries); err != nil {
+		t.Fatalf("WritePlist: %v", err)
+	}
+	if buf.String() != string(golden) {
+		t.Fatalf("output differs from golden:\n%s", buf.String())
+	}
+}
+
+func TestReadPlistErrors(t *testing.T) {
+	if _, err := ReadPlist(strings.NewReader("<array/>"), PartNoun); err == nil || err.Error() != "not a property list" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	input := plistHeader + "<array>\n<dict>\n<key>phrase</key><string>x</string>\n</dict>\n</array>\n</plist>\n"
+	if _, err := ReadPlist(strings.NewReader(input), PartNoun); err == nil || !strings.Contains(err.Error(), "line 7: ") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,473 @@
+package gimedic
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// SKKCoding selects the character encoding of a written SKK jisyo.
+type SKKCoding int
+
+const (
+	SKKUTF8 SKKCoding = iota
+	SKKEUCJP
+)
+
+// skkOkuri maps okurigana consonants onto the Part, the kana of the
+// 連用形 and the kana that ends the dictionary form. SKK keys carry only
+// the consonant, so a key alone is ambiguous: "おk /起/" is 起きる, an 一段
+// verb, "たかk /高/" is 高く, an adjective, and "かi /書/" is 書いた. A 五段
+// verb is told by the jisyo recording both its 連用形 and its dictionary
+// form in [okuri/word/] blocks, which neither 一段 verbs nor adjectives
+// take; ワ行五段 verbs alone end with the okurigana 'u'.
+var skkOkuri = []skkOkuriRow{
+	{'u', "", "う", PartVerbGodanWaRow},
+	{'k', "き", "く", PartVerbGodanKaRow},
+	{'s', "し", "す", PartVerbGodanSaRow},
+	{'t', "ち", "つ", PartVerbGodanTaRow},
+	{'n', "に", "ぬ", PartVerbGodanNaRow},
+	{'m', "み", "む", PartVerbGodanMaRow},
+	{'r', "り", "る", PartVerbGodanRaRow},
+	{'g', "ぎ", "ぐ", PartVerbGodanGaRow},
+	{'b', "び", "ぶ", PartVerbGodanBaRow},
+	{'i', "", "い", PartAdjective},
+}
+
+type skkOkuriRow struct {
+	consonant byte
+	renyo     string
+	kana      string
+	part      Part
+}
+
+var skkCodingPattern = regexp.MustCompile(`coding:\s*([A-Za-z0-9_-]+)`)
+
+// ReadSKK reads an SKK jisyo and returns one entry per candidate.
+//
+// The encoding is taken from a "coding:" declaration on the first line;
+// without one, the jisyo is read as UTF-8 when it is valid UTF-8 and as
+// EUC-JP otherwise. Annotations after ';' become comments. Okuri-nasi
+// entries are nouns, or prefixes and suffixes for keys marked with '>'.
+// Okuri-ari entries are expanded to their dictionary form where the jisyo
+// tells their class: 五段 verbs whose 連用形 and dictionary form are both
+// recorded in okuri blocks, ワ行五段 verbs with the okurigana 'u', and
+// adjectives with the okurigana 'i' whose stem is known from the
+// okurigana く or from ending with し. The other okuri-ari entries are
+// returned as skipped, as are numeric conversion entries.
+func ReadSKK(r io.Reader) ([]*UserDictionary_Entry, []Skipped, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	scanner := bufio.NewScanner(newTextReader(bytes.NewReader(raw), skkEncoding(raw)))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var lines []skkLine
+	evidence := skkEvidence{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, ";") {
+			continue
+		}
+		index := strings.Index(text, " /")
+		if index <= 0 || !strings.HasSuffix(text, "/") {
+			return nil, nil, fmt.Errorf("line %d: malformed jisyo line", line)
+		}
+		key := text[:index]
+		candidates, err := parseSKKCandidates(text[index+1:], skkOkuriAri(key))
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if skkOkuriAri(key) {
+			evidence.add(key, candidates)
+		}
+		lines = append(lines, skkLine{line: line, key: key, candidates: candidates})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	var entries []*UserDictionary_Entry
+	var skipped []Skipped
+	for _, l := range lines {
+		for _, c := range l.candidates {
+			record, reason := skkRecord(l.key, c.word, evidence)
+			if reason != "" {
+				skipped = append(skipped, Skipped{Line: l.line, Key: l.key, Value: c.word, Reason: reason})
+				continue
+			}
+			record.Comment = c.annotation
+			entries = append(entries, record.Entry())
+		}
+	}
+	return entries, skipped, nil
+}
+
+type skkLine struct {
+	line       int
+	key        string
+	candidates []skkCandidate
+}
+
+// skkEvidence holds the okurigana a jisyo records for each okuri-ari stem
+// and word, from the [okuri/word/] blocks and from the vowel okurigana 'i'
+// and 'u', which are the kana themselves.
+type skkEvidence map[string]map[string]bool
+
+func (e skkEvidence) add(key string, candidates []skkCandidate) {
+	stem, consonant := key[:len(key)-1], key[len(key)-1]
+	for _, c := range candidates {
+		okuri := c.okuri
+		switch consonant {
+		case 'i':
+			okuri = append(okuri, "い")
+		case 'u':
+			okuri = append(okuri, "う")
+		}
+		id := stem + "\x00" + c.word
+		if e[id] == nil {
+			e[id] = map[string]bool{}
+		}
+		for _, kana := range okuri {
+			e[id][kana] = true
+		}
+	}
+}
+
+func (e skkEvidence) has(stem, word string, kana ...string) bool {
+	for _, k := range kana {
+		if !e[stem+"\x00"+word][k] {
+			return false
+		}
+	}
+	return true
+}
+
+// verb reports whether the stem and word make a 五段 verb of the row
+// whose 連用形 can end with い: カ行 and ガ行 by イ音便, and ワ行.
+func (e skkEvidence) verb(stem, word string) bool {
+	return e.has(stem, word, "き", "く") || e.has(stem, word, "ぎ", "ぐ") || e.has(stem, word, "う")
+}
+
+// adjective reports whether the stem and word are a known adjective stem.
+func (e skkEvidence) adjective(stem, word string) bool {
+	return !e.verb(stem, word) && (e.has(stem, word, "く") || strings.HasSuffix(word, "し"))
+}
+
+func skkEncoding(raw []byte) encoding.Encoding {
+	first, _, _ := bytes.Cut(raw, []byte{'\n'})
+	if m := skkCodingPattern.FindSubmatch(first); m != nil {
+		switch strings.ToLower(string(m[1])) {
+		case "utf-8", "utf8", "utf-8-unix", "utf-8-dos":
+			return unicode.UTF8
+		case "euc-jp", "euc-japan", "euc-jp-unix", "euc-japan-unix", "euc-jisx0213":
+			return japanese.EUCJP
+		}
+	}
+	if utf8.Valid(raw) {
+		return unicode.UTF8
+	}
+	return japanese.EUCJP
+}
+
+type skkCandidate struct {
+	word       string
+	annotation string
+	// okuri lists the okurigana the [okuri/word/] blocks record for the
+	// word.
+	okuri []string
+}
+
+// parseSKKCandidates parses "/word;annotation/word/", taking the
+// "[okuri/word/]" blocks apart when okuri tells that the entry is
+// okuri-ari.
+func parseSKKCandidates(body string, okuri bool) ([]skkCandidate, error) {
+	body = strings.TrimPrefix(body, "/")
+	body = strings.TrimSuffix(body, "/")
+	var candidates []skkCandidate
+	blocks := map[string][]string{}
+	block := ""
+	inBlock := false
+	for _, token := range strings.Split(body, "/") {
+		if inBlock {
+			if token == "]" {
+				inBlock = false
+				continue
+			}
+			word, _, _ := strings.Cut(token, ";")
+			if word, err := unquoteSKK(word); err == nil {
+				blocks[word] = append(blocks[word], block)
+			}
+			continue
+		}
+		if okuri && strings.HasPrefix(token, "[") {
+			block = strings.TrimPrefix(token, "[")
+			inBlock = true
+			continue
+		}
+		if token == "" {
+			continue
+		}
+		word, annotation, _ := strings.Cut(token, ";")
+		word, err := unquoteSKK(word)
+		if err != nil {
+			return nil, err
+		}
+		annotation, err = unquoteSKK(annotation)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, skkCandidate{word: word, annotation: annotation})
+	}
+	for i, c := range candidates {
+		candidates[i].okuri = blocks[c.word]
+	}
+	return candidates, nil
+}
+
+// skkRecord converts a jisyo key and candidate into a record, or returns
+// the reason it cannot be converted.
+func skkRecord(key, word string, evidence skkEvidence) (Record, string) {
+	if strings.Contains(key, "#") {
+		return Record{}, "numeric conversion entries are not supported"
+	}
+	if skkOkuriAri(key) {
+		stem, consonant := key[:len(key)-1], key[len(key)-1]
+		for _, o := range skkOkuri {
+			if o.consonant != consonant {
+				continue
+			}
+			var known bool
+			switch o.part {
+			case PartVerbGodanWaRow:
+				known = true
+			case PartAdjective:
+				known = evidence.adjective(stem, word)
+			default:
+				known = evidence.has(stem, word, o.renyo, o.kana)
+			}
+			if known {
+				return Record{Key: stem + o.kana, Value: word + o.kana, Pos: o.part}, ""
+			}
+		}
+		return Record{}, fmt.Sprintf("okurigana %q does not tell the verb or adjective class", key[len(key)-1:])
+	}
+	switch {
+	case len(key) > 1 && strings.HasSuffix(key, ">"):
+		return Record{Key: strings.TrimSuffix(key, ">"), Value: word, Pos: PartPrefix}, ""
+	case len(key) > 1 && strings.HasPrefix(key, ">"):
+		return Record{Key: strings.TrimPrefix(key, ">"), Value: word, Pos: PartSuffixGeneral}, ""
+	}
+	return Record{Key: key, Value: word, Pos: PartNoun}, ""
+}
+
+// skkOkuriAri reports whether the key is an okuri-ari one: kana followed
+// by the okurigana consonant.
+func skkOkuriAri(key string) bool {
+	last := key[len(key)-1]
+	return len(key) > 1 && last >= 'a' && last <= 'z' && key[len(key)-2] >= utf8.RuneSelf
+}
+
+// unquoteSKK evaluates the (concat "...") form SKK uses for candidates
+// that contain '/' or ';'.
+func unquoteSKK(s string) (string, error) {
+	if !strings.HasPrefix(s, `(concat "`) || !strings.HasSuffix(s, `")`) {
+		return s, nil
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(s, `(concat `), ")")
+	var b strings.Builder
+	for _, part := range strings.Split(body, `" "`) {
+		part = strings.TrimPrefix(strings.TrimSuffix(part, `"`), `"`)
+		for i := 0; i < len(part); i++ {
+			if part[i] != '\\' || i+1 == len(part) {
+				b.WriteByte(part[i])
+				continue
+			}
+			i++
+			if part[i] >= '0' && part[i] <= '7' {
+				end := i
+				for end < len(part) && end < i+3 && part[end] >= '0' && part[end] <= '7' {
+					end++
+				}
+				n, err := strconv.ParseUint(part[i:end], 8, 8)
+				if err != nil {
+					return "", fmt.Errorf("invalid escape in %s", s)
+				}
+				b.WriteByte(byte(n))
+				i = end - 1
+				continue
+			}
+			b.WriteByte(part[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// quoteSKK wraps s in (concat "...") when it contains characters that
+// would break the jisyo line.
+func quoteSKK(s string) string {
+	if !strings.ContainsAny(s, "/;\"\\") {
+		return s
+	}
+	var b strings.Builder
+	b.WriteString(`(concat "`)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '/', ';', '"', '\\':
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteString(`")`)
+	return b.String()
+}
+
+// WriteSKK writes entries as an SKK jisyo with okuri-ari and okuri-nasi
+// sections, sorted the way SKK expects: okuri-ari descending and
+// okuri-nasi ascending. Candidates sharing a key are written on one line
+// and comments become annotations. 五段 verbs and adjectives whose key
+// and value end with the same kana are written as okuri-ari entries, with
+// the okuri blocks and, for adjectives, the 'k' entry ReadSKK needs to
+// tell their class; prefixes and suffixes get the '>' marker. Entries that
+// cannot be written as a jisyo line are left out and returned as skipped.
+func WriteSKK(w io.Writer, entries []*UserDictionary_Entry, coding SKKCoding) ([]Skipped, error) {
+	enc := encoding.Encoding(unicode.UTF8)
+	codingName := "utf-8"
+	if coding == SKKEUCJP {
+		enc = japanese.EUCJP
+		codingName = "euc-jp"
+	}
+
+	okuriAri := map[string][]string{}
+	okuriNasi := map[string][]string{}
+	blocks := map[string][]skkBlock{}
+	var skipped []Skipped
+	for _, entry := range entries {
+		key := singleLine.Replace(entry.GetKey())
+		word := singleLine.Replace(entry.GetValue())
+		comment := singleLine.Replace(entry.GetComment())
+		if key == "" || strings.ContainsAny(key, " /;#") {
+			skipped = append(skipped, Skipped{Key: entry.GetKey(), Value: entry.GetValue(), Reason: "key cannot be an SKK key"})
+			continue
+		}
+		if _, err := enc.NewEncoder().String(key + word + comment); err != nil {
+			skipped = append(skipped, Skipped{Key: entry.GetKey(), Value: entry.GetValue(), Reason: "not representable in " + codingName})
+			continue
+		}
+		part := Part(entry.GetPos())
+		sections := okuriNasi
+		if stem, okuriWord, o, ok := skkOkuriForm(key, word, part); ok {
+			key, word, sections = stem+string(o.consonant), okuriWord, okuriAri
+			switch o.part {
+			case PartVerbGodanWaRow:
+			case PartAdjective:
+				adverb := stem + "k"
+				okuriAri[adverb] = appendUnique(okuriAri[adverb], quoteSKK(word))
+				blocks[adverb] = addSKKBlock(blocks[adverb], "く", quoteSKK(word))
+			default:
+				blocks[key] = addSKKBlock(blocks[key], o.renyo, quoteSKK(word))
+				blocks[key] = addSKKBlock(blocks[key], o.kana, quoteSKK(word))
+			}
+		} else if part == PartPrefix {
+			key += ">"
+		} else if part == PartSuffixGeneral {
+			key = ">" + key
+		}
+		candidate := quoteSKK(word)
+		if comment != "" {
+			candidate += ";" + quoteSKK(comment)
+		}
+		sections[key] = appendUnique(sections[key], candidate)
+	}
+
+	encoder := transform.NewWriter(w, enc.NewEncoder())
+	writer := bufio.NewWriter(encoder)
+	fmt.Fprintf(writer, ";; -*- mode: fundamental; coding: %s -*-\n", codingName)
+	fmt.Fprintln(writer, ";; okuri-ari entries.")
+	for _, key := range sortedKeys(okuriAri, true) {
+		fmt.Fprintf(writer, "%s /%s/", key, strings.Join(okuriAri[key], "/"))
+		for _, block := range blocks[key] {
+			fmt.Fprintf(writer, "[%s/%s/]/", block.okuri, strings.Join(block.words, "/"))
+		}
+		fmt.Fprintln(writer)
+	}
+	fmt.Fprintln(writer, ";; okuri-nasi entries.")
+	for _, key := range sortedKeys(okuriNasi, false) {
+		fmt.Fprintf(writer, "%s /%s/\n", key, strings.Join(okuriNasi[key], "/"))
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return skipped, nil
+}
+
+// skkOkuriForm returns the okuri-ari stem and word of a 五段 verb or an
+// adjective in dictionary form, with its skkOkuri row.
+func skkOkuriForm(key, word string, part Part) (string, string, skkOkuriRow, bool) {
+	for _, o := range skkOkuri {
+		if o.part != part {
+			continue
+		}
+		stem := strings.TrimSuffix(key, o.kana)
+		okuriWord := strings.TrimSuffix(word, o.kana)
+		if stem == key || okuriWord == word || stem == "" || okuriWord == "" {
+			break
+		}
+		return stem, okuriWord, o, true
+	}
+	return "", "", skkOkuriRow{}, false
+}
+
+// skkBlock is an [okuri/word/] block of an okuri-ari line.
+type skkBlock struct {
+	okuri string
+	words []string
+}
+
+func addSKKBlock(blocks []skkBlock, okuri, word string) []skkBlock {
+	for i, b := range blocks {
+		if b.okuri == okuri {
+			blocks[i].words = appendUnique(b.words, word)
+			return blocks
+		}
+	}
+	return append(blocks, skkBlock{okuri: okuri, words: []string{word}})
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+func sortedKeys(m map[string][]string, descending bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	if descending {
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	} else {
+		sort.Strings(keys)
+	}
+	return keys
+}
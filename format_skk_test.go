@@ -0,0 +1,130 @@
+package gimedic
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+const skkJisyo = `;; -*- mode: fundamental; coding: euc-jp -*-
+;; okuri-ari entries.
+わたs /渡/[し/渡/]/[す/渡/]/
+よr /寄/[る/寄/]/
+たかk /高/[く/高/]/
+たかi /高/
+かk /書/[き/書/]/[く/書/]/
+かi /書/
+おもu /思/
+おもi /思/
+おk /起/
+おz /怖/
+うつくしi /美し/
+;; okuri-nasi entries.
+かんじ /漢字;kanji/幹事/(concat "a\057b")/
+かっこ /[/]/
+ご> /御/
+>てき /的/
+#だい /#1台/
+`
+
+func TestReadSKK(t *testing.T) {
+	raw, _, err := transform.Bytes(japanese.EUCJP.NewEncoder(), []byte(skkJisyo))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	entries, skipped, err := ReadSKK(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadSKK: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.GetKey()+"|"+e.GetValue()+"|"+Part(e.GetPos()).String()+"|"+e.GetComment())
+	}
+	want := []string{
+		"わたす|渡す|動詞サ行五段|",
+		"たかい|高い|形容詞|",
+		"かく|書く|動詞カ行五段|",
+		"おもう|思う|動詞ワ行五段|",
+		"うつくしい|美しい|形容詞|",
+		"かんじ|漢字|名詞|kanji",
+		"かんじ|幹事|名詞|",
+		"かんじ|a/b|名詞|",
+		"かっこ|[|名詞|",
+		"かっこ|]|名詞|",
+		"ご|御|接頭語|",
+		"てき|的|接尾一般|",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected entries:\n%s", strings.Join(got, "\n"))
+	}
+	var lines []int
+	for _, s := range skipped {
+		lines = append(lines, s.Line)
+	}
+	// 寄 may be 寄る or 寄せる, 高く and the い of 書いた and 思い are
+	// conjugated forms, and 起 and 怖 have no blocks to tell them.
+	if fmt.Sprint(lines) != "[4 5 8 10 11 12 19]" {
+		t.Fatalf("unexpected skipped: %v", skipped)
+	}
+}
+
+func TestWriteSKK(t *testing.T) {
+	entries := []*UserDictionary_Entry{
+		Record{Key: "かんじ", Value: "漢字", Pos: PartNoun, Comment: "kanji"}.Entry(),
+		Record{Key: "あい", Value: "愛", Pos: PartNoun}.Entry(),
+		Record{Key: "かんじ", Value: "幹事", Pos: PartNoun}.Entry(),
+		Record{Key: "かんじ", Value: "漢字", Pos: PartNoun, Comment: "kanji"}.Entry(),
+		Record{Key: "わたす", Value: "渡す", Pos: PartVerbGodanSaRow}.Entry(),
+		Record{Key: "たかい", Value: "高い", Pos: PartAdjective}.Entry(),
+		Record{Key: "おもう", Value: "思う", Pos: PartVerbGodanWaRow}.Entry(),
+		Record{Key: "てき", Value: "的", Pos: PartSuffixGeneral}.Entry(),
+		Record{Key: "すらっしゅ", Value: "a/b", Pos: PartSymbol}.Entry(),
+		Record{Key: "えもじ", Value: "🍣", Pos: PartEmoticon}.Entry(),
+		Record{Key: "a b", Value: "x", Pos: PartNoun}.Entry(),
+	}
+	var buf bytes.Buffer
+	skipped, err := WriteSKK(&buf, entries, SKKUTF8)
+	if err != nil {
+		t.Fatalf("WriteSKK: %v", err)
+	}
+	want := `;; -*- mode: fundamental; coding: utf-8 -*-
+;; okuri-ari entries.
+わたs /渡/[し/渡/]/[す/渡/]/
+たかk /高/[く/高/]/
+たかi /高/
+おもu /思/
+;; okuri-nasi entries.
+>てき /的/
+あい /愛/
+えもじ /🍣/
+かんじ /漢字;kanji/幹事/
+すらっしゅ /(concat "a\057b")/
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected jisyo:\n%s", buf.String())
+	}
+	if len(skipped) != 1 || skipped[0].Key != "a b" {
+		t.Fatalf("unexpected skipped: %v", skipped)
+	}
+
+	buf.Reset()
+	skipped, err = WriteSKK(&buf, entries, SKKEUCJP)
+	if err != nil {
+		t.Fatalf("WriteSKK: %v", err)
+	}
+	if len(skipped) != 2 || skipped[0].Value != "🍣" {
+		t.Fatalf("unexpected skipped: %v", skipped)
+	}
+	// Only the 'k' entry written for 高く comes back skipped.
+	read, skipped, err := ReadSKK(&buf)
+	if err != nil {
+		t.Fatalf("ReadSKK: %v", err)
+	}
+	if len(read) != 8 || len(skipped) != 1 || skipped[0].Key != "たかk" {
+		t.Fatalf("unexpected round trip: %v %v", read, skipped)
+	}
+}
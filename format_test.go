@@ -0,0 +1,203 @@
+package gimedic
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sampleStorage() *UserDictionaryStorage {
+	return &UserDictionaryStorage{
+		Dictionaries: []*UserDictionary{
+			{
+				Id:   ptr(uint64(18446744073709551615)),
+				Name: ptr("main"),
+				Entries: []*UserDictionary_Entry{
+					{
+						Key:     ptr("ぐーぐる"),
+						Value:   ptr("Google"),
+						Pos:     ptr(UserDictionary_ORGANIZATION_NAME),
+						Comment: ptr("line1\nline2\t\"quoted\", \\"),
+					},
+					{
+						Key:    ptr("かお"),
+						Value:  ptr("(^_^)"),
+						Pos:    ptr(UserDictionary_EMOTICON),
+						Locale: ptr("ja"),
+					},
+				},
+			},
+			{
+				Id:   ptr(uint64(2)),
+				Name: ptr("empty"),
+			},
+		},
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, sampleStorage()); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	var doc storageJSON
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(doc.Dictionaries) != 2 {
+		t.Fatalf("unexpected dictionaries: %d", len(doc.Dictionaries))
+	}
+	if doc.Dictionaries[0].ID != 18446744073709551615 {
+		t.Fatalf("id lost precision: %d", doc.Dictionaries[0].ID)
+	}
+	entry := doc.Dictionaries[0].Entries[0]
+	if entry.Comment != "line1\nline2\t\"quoted\", \\" {
+		t.Fatalf("unexpected comment: %q", entry.Comment)
+	}
+	if entry.Pos != 8 || entry.PosName != "組織" {
+		t.Fatalf("unexpected pos: %d %s", entry.Pos, entry.PosName)
+	}
+	if doc.Dictionaries[1].Entries == nil {
+		t.Fatal("empty dictionary should have an empty entries array")
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteNDJSON(&buf, sampleStorage()); err != nil {
+		t.Fatalf("WriteNDJSON: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 3 || lines[2] != `{"dictionary_id":"2","dictionary":"empty"}` {
+		t.Fatalf("unexpected lines: %q", lines)
+	}
+	var record recordJSON
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if record.DictionaryName != "main" || record.Value != "(^_^)" || record.Locale != "ja" {
+		t.Fatalf("unexpected record: %#v", record)
+	}
+}
+
+func TestWriteTSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteTSV(&buf, sampleStorage()); err != nil {
+		t.Fatalf("WriteTSV: %v", err)
+	}
+	want := "dictionary_id\tdictionary\tkey\tvalue\tpos\tpos_name\tcomment\tlocale\n" +
+		"18446744073709551615\tmain\tぐーぐる\tGoogle\t8\t組織\tline1\\nline2\\t\"quoted\", \\\\\t\n" +
+		"18446744073709551615\tmain\tかお\t(^_^)\t15\t顔文字\t\tja\n" +
+		"2\tempty\t\t\t\t\t\t\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected TSV:\n%s", buf.String())
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, sampleStorage()); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "dictionary_id,dictionary,key,value,pos,pos_name,comment,locale\n" +
+		"18446744073709551615,main,ぐーぐる,Google,8,組織,\"line1\nline2\t\"\"quoted\"\", \\\",\n" +
+		"18446744073709551615,main,かお,(^_^),15,顔文字,,ja\n" +
+		"2,empty,,,,,,\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected CSV:\n%s", buf.String())
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteYAML(&buf, sampleStorage()); err != nil {
+		t.Fatalf("WriteYAML: %v", err)
+	}
+	for _, want := range []string{
+		"  - id: 18446744073709551615\n",
+		"        comment: \"line1\\nline2\\t\\\"quoted\\\", \\\\\"\n",
+		"        pos_name: \"顔文字\"\n",
+		"    name: \"empty\"\n    entries: []\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("missing %q in YAML:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		write func(io.Writer, *UserDictionaryStorage) error
+		read  func(io.Reader) (*UserDictionaryStorage, error)
+	}{
+		{"json", WriteJSON, ReadJSON},
+		{"ndjson", WriteNDJSON, ReadNDJSON},
+		{"csv", WriteCSV, ReadCSV},
+		{"tsv", WriteTSV, ReadTSV},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tc.write(&buf, sampleStorage()); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			storage, err := tc.read(&buf)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			want := flatRecords(sampleStorage())
+			got := flatRecords(storage)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("round trip mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadErrorsHaveLineNumbers(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		read  func(io.Reader) (*UserDictionaryStorage, error)
+		want  string
+	}{
+		{"csv", "key,value,pos\nあ,亜,名詞\nい,胃,へん\n", ReadCSV, `line 3: unknown pos "へん"`},
+		{"tsv", "key\tvalue\tpos_name\n\nあ\t\t名詞\n", ReadTSV, "line 3: empty value"},
+		{"tsv-escape", "key\tvalue\nあ\\x\t亜\n", ReadTSV, `line 2: column 1: unknown escape \x`},
+		{"ndjson", "{\"key\":\"あ\",\"value\":\"亜\"}\n{\"key\":\"い\",\"value\":\"胃\",\"pos\":99}\n", ReadNDJSON, "line 2: pos 99 out of range"},
+		{"json", "{\n  \"dictionaries\": [\n    {\"name\": 1}\n  ]\n}\n", ReadJSON, "line 3: "},
+		{"missing-column", "key,comment\nあ,x\n", ReadCSV, `line 1: missing column "value"`},
+		{"pos-mismatch", "key,value,pos,pos_name\nあ,亜,1,人名\n", ReadCSV, `line 2: pos "1" and pos_name "人名" disagree`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.read(strings.NewReader(tc.input))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.HasPrefix(err.Error(), tc.want) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadCSVDefaultsAndLabels(t *testing.T) {
+	storage, err := ReadCSV(strings.NewReader("\ufeffkey,value,pos,dictionary\nあ,亜,,x\nい,胃,人名,x\nう,鵜,,y\n"))
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	if len(storage.GetDictionaries()) != 2 {
+		t.Fatalf("unexpected dictionaries: %d", len(storage.GetDictionaries()))
+	}
+	entries := storage.GetDictionaries()[0].GetEntries()
+	if entries[0].GetPos() != UserDictionary_NOUN || entries[1].GetPos() != UserDictionary_PERSONAL_NAME {
+		t.Fatalf("unexpected pos: %v %v", entries[0].GetPos(), entries[1].GetPos())
+	}
+}
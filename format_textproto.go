@@ -0,0 +1,128 @@
+package gimedic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// textprotoUnknownPrefix starts the comments that carry unknown fields.
+const textprotoUnknownPrefix = "# unknown "
+
+var textprotoUnknownPattern = regexp.MustCompile(`^# unknown (\S+) ([0-9a-f]+)$`)
+
+// textprotoSeparator matches the field separator prototext randomly
+// widens to keep its output from being relied on. It is narrowed again
+// so that dumps made by different builds diff cleanly.
+var textprotoSeparator = regexp.MustCompile(`(?m)^(\s*[A-Za-z0-9_]+):\s+`)
+
+// WriteTextproto writes the storage in the protobuf text format. Field
+// presence is kept as is: unset fields are left out and set ones are
+// written even when they hold the default. Unknown fields, which the
+// text format cannot express, are written as trailing comments such as
+// "# unknown dictionaries[0].entries[2] 6a0161" that ReadTextproto
+// restores, so that decoding and re-encoding gives back the bytes
+// proto.Marshal produces for the storage.
+func WriteTextproto(w io.Writer, storage *UserDictionaryStorage) error {
+	known := proto.Clone(storage).(*UserDictionaryStorage)
+	var unknowns []string
+	strip := func(path string, m proto.Message) {
+		r := m.ProtoReflect()
+		if raw := r.GetUnknown(); len(raw) > 0 {
+			unknowns = append(unknowns, textprotoUnknownPrefix+path+" "+hex.EncodeToString(raw))
+			r.SetUnknown(nil)
+		}
+	}
+	strip(".", known)
+	for i, dict := range known.GetDictionaries() {
+		strip(fmt.Sprintf("dictionaries[%d]", i), dict)
+		for j, entry := range dict.GetEntries() {
+			strip(fmt.Sprintf("dictionaries[%d].entries[%d]", i, j), entry)
+		}
+	}
+	raw, err := prototext.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(known)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(w)
+	writer.Write(textprotoSeparator.ReplaceAll(raw, []byte("$1: ")))
+	for _, line := range unknowns {
+		fmt.Fprintln(writer, line)
+	}
+	return writer.Flush()
+}
+
+// ReadTextproto reads a storage written by WriteTextproto, restoring the
+// unknown fields from its comments.
+func ReadTextproto(r io.Reader) (*UserDictionaryStorage, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var storage UserDictionaryStorage
+	if err := prototext.Unmarshal(raw, &storage); err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(text, textprotoUnknownPrefix) {
+			continue
+		}
+		m := textprotoUnknownPattern.FindStringSubmatch(text)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: malformed unknown field comment", line)
+		}
+		target, err := textprotoTarget(&storage, m[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		fields, err := hex.DecodeString(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		r := target.ProtoReflect()
+		r.SetUnknown(append(r.GetUnknown(), fields...))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &storage, nil
+}
+
+var textprotoPathPattern = regexp.MustCompile(`^dictionaries\[(\d+)\](?:\.entries\[(\d+)\])?$`)
+
+// textprotoTarget finds the message an unknown field comment refers to.
+func textprotoTarget(storage *UserDictionaryStorage, path string) (proto.Message, error) {
+	if path == "." {
+		return storage, nil
+	}
+	m := textprotoPathPattern.FindStringSubmatch(path)
+	if m == nil {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+	i, _ := strconv.Atoi(m[1])
+	if i >= len(storage.GetDictionaries()) {
+		return nil, fmt.Errorf("path %q: no such dictionary", path)
+	}
+	dict := storage.GetDictionaries()[i]
+	if m[2] == "" {
+		return dict, nil
+	}
+	j, _ := strconv.Atoi(m[2])
+	if j >= len(dict.GetEntries()) {
+		return nil, fmt.Errorf("path %q: no such entry", path)
+	}
+	return dict.GetEntries()[j], nil
+}
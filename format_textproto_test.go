@@ -0,0 +1,66 @@
+package gimedic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTextprotoRoundTrip(t *testing.T) {
+	storage := sampleStorage()
+	storage.Version = ptr(int32(0))
+	storage.Dictionaries[0].Entries[1].Comment = ptr("")
+	// Field 99 = 1 on the storage and on an entry.
+	storage.ProtoReflect().SetUnknown([]byte{0x98, 0x06, 0x01})
+	storage.Dictionaries[0].Entries[0].ProtoReflect().SetUnknown([]byte{0x98, 0x06, 0x01})
+	want, err := proto.Marshal(storage)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTextproto(&buf, storage); err != nil {
+		t.Fatalf("WriteTextproto: %v", err)
+	}
+	text := buf.String()
+	for _, fragment := range []string{
+		"version: 0",
+		`comment: ""`,
+		"# unknown . 980601",
+		"# unknown dictionaries[0].entries[0] 980601",
+	} {
+		if !strings.Contains(text, fragment) {
+			t.Fatalf("missing %q in:\n%s", fragment, text)
+		}
+	}
+	if len(storage.ProtoReflect().GetUnknown()) == 0 {
+		t.Fatal("WriteTextproto modified its input")
+	}
+
+	got, err := ReadTextproto(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("ReadTextproto: %v", err)
+	}
+	raw, err := proto.Marshal(got)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !bytes.Equal(raw, want) {
+		t.Fatalf("round trip differs:\n got %x\nwant %x", raw, want)
+	}
+}
+
+func TestReadTextprotoErrors(t *testing.T) {
+	for _, input := range []string{
+		"dictionaries { name: \"a\" }\n# unknown dictionaries[1] 980601\n",
+		"dictionaries { name: \"a\" }\n# unknown dictionaries[0].entries[0] 980601\n",
+		"# unknown entries[0] 980601\n",
+		"# unknown . zz\n",
+	} {
+		if _, err := ReadTextproto(strings.NewReader(input)); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
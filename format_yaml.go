@@ -0,0 +1,50 @@
+package gimedic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteYAML writes the storage as a YAML document with the same shape as WriteJSON.
+// Strings are always double-quoted so that no value is reinterpreted by YAML parsers.
+func WriteYAML(w io.Writer, storage *UserDictionaryStorage) error {
+	doc := newStorageJSON(storage)
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "version: %d\n", doc.Version)
+	if len(doc.Dictionaries) == 0 {
+		fmt.Fprintln(writer, "dictionaries: []")
+		return writer.Flush()
+	}
+	fmt.Fprintln(writer, "dictionaries:")
+	for _, dict := range doc.Dictionaries {
+		fmt.Fprintf(writer, "  - id: %d\n", dict.ID)
+		fmt.Fprintf(writer, "    name: %s\n", yamlString(dict.Name))
+		if len(dict.Entries) == 0 {
+			fmt.Fprintln(writer, "    entries: []")
+			continue
+		}
+		fmt.Fprintln(writer, "    entries:")
+		for _, entry := range dict.Entries {
+			fmt.Fprintf(writer, "      - key: %s\n", yamlString(entry.Key))
+			fmt.Fprintf(writer, "        value: %s\n", yamlString(entry.Value))
+			fmt.Fprintf(writer, "        pos: %d\n", entry.Pos)
+			fmt.Fprintf(writer, "        pos_name: %s\n", yamlString(entry.PosName))
+			fmt.Fprintf(writer, "        comment: %s\n", yamlString(entry.Comment))
+			fmt.Fprintf(writer, "        locale: %s\n", yamlString(entry.Locale))
+		}
+	}
+	return writer.Flush()
+}
+
+// yamlString quotes s as a YAML double-quoted scalar.
+// JSON string syntax is a subset of YAML double-quoted scalars.
+func yamlString(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return string(bytes.TrimRight(buf.Bytes(), "\n"))
+}
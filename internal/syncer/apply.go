@@ -2,14 +2,14 @@ package syncer
 
 import "github.com/kyoh86/gimedic"
 
-func ensureDictionary(storage *gimedic.UserDictionaryStorage, name string) *gimedic.UserDictionary {
-	if name == "" {
-		name = "default"
-	}
-	for _, dict := range storage.GetDictionaries() {
-		if dict.GetName() == name {
-			return dict
-		}
+// EnsureDictionary returns the dictionary named name, appending a new
+// empty one with a unique id when there is none. An empty name means
+// "default", and an unnamed dictionary is found as "default" the way
+// snapshots list it.
+func EnsureDictionary(storage *gimedic.UserDictionaryStorage, name string) *gimedic.UserDictionary {
+	name = dictionaryName(name)
+	if dict := findDictionary(storage, name); dict != nil {
+		return dict
 	}
 	newID := UniqueDictionaryID(storage)
 	newDict := &gimedic.UserDictionary{
@@ -21,9 +21,33 @@ func ensureDictionary(storage *gimedic.UserDictionaryStorage, name string) *gime
 	return newDict
 }
 
-func deleteEntry(dict *gimedic.UserDictionary, key string) bool {
+func findEntry(dict *gimedic.UserDictionary, id string) *gimedic.UserDictionary_Entry {
+	for _, entry := range dict.GetEntries() {
+		if entryID(entry) == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+// soleEntry returns the only entry with the key and value, if there is
+// exactly one.
+func soleEntry(dict *gimedic.UserDictionary, key, value string) *gimedic.UserDictionary_Entry {
+	var found *gimedic.UserDictionary_Entry
+	for _, entry := range dict.GetEntries() {
+		if entry.GetKey() == key && entry.GetValue() == value {
+			if found != nil {
+				return nil
+			}
+			found = entry
+		}
+	}
+	return found
+}
+
+func deleteEntry(dict *gimedic.UserDictionary, id string) bool {
 	for i, entry := range dict.GetEntries() {
-		if entry.GetKey()+"\u0000"+entry.GetValue() == key {
+		if entryID(entry) == id {
 			dict.Entries = append(dict.Entries[:i], dict.Entries[i+1:]...)
 			return true
 		}
@@ -31,6 +55,10 @@ func deleteEntry(dict *gimedic.UserDictionary, key string) bool {
 	return false
 }
 
+func entryID(entry *gimedic.UserDictionary_Entry) string {
+	return gimedic.EntryID(entry.GetKey(), entry.GetValue(), int32(entry.GetPos()))
+}
+
 func updateEntry(entry *gimedic.UserDictionary_Entry, event JournalEvent) bool {
 	changed := false
 	if entry.GetComment() != event.Comment {
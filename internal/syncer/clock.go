@@ -0,0 +1,182 @@
+package syncer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kyoh86/gimedic"
+)
+
+var getNow = time.Now
+
+// HLC is a hybrid logical clock reading: the wall clock in milliseconds
+// and a counter ordering readings within the same millisecond. A peer
+// never issues a reading lower than one it has issued or seen, so an
+// event recorded after another one was pulled is ordered after it even
+// when the wall clocks of the peers disagree.
+type HLC struct {
+	Wall    int64  `json:"wall"`
+	Logical uint32 `json:"logical"`
+}
+
+// Compare returns -1, 0 or +1 as c is before, equal to or after o.
+func (c HLC) Compare(o HLC) int {
+	switch {
+	case c.Wall < o.Wall:
+		return -1
+	case c.Wall > o.Wall:
+		return 1
+	case c.Logical < o.Logical:
+		return -1
+	case c.Logical > o.Logical:
+		return 1
+	}
+	return 0
+}
+
+// Tick returns the reading for a local event.
+func (c HLC) Tick() HLC {
+	now := getNow().UnixMilli()
+	if now > c.Wall {
+		return HLC{Wall: now}
+	}
+	return HLC{Wall: c.Wall, Logical: c.Logical + 1}
+}
+
+// Observe returns the clock after seeing a remote reading.
+func (c HLC) Observe(remote HLC) HLC {
+	now := getNow().UnixMilli()
+	wall := max(c.Wall, remote.Wall, now)
+	switch {
+	case wall == c.Wall && wall == remote.Wall:
+		return HLC{Wall: wall, Logical: max(c.Logical, remote.Logical) + 1}
+	case wall == c.Wall:
+		return HLC{Wall: wall, Logical: c.Logical + 1}
+	case wall == remote.Wall:
+		return HLC{Wall: wall, Logical: remote.Logical + 1}
+	}
+	return HLC{Wall: wall}
+}
+
+// Stamp orders writes to an entry: by clock, then by origin peer id.
+type Stamp struct {
+	Clock  HLC    `json:"hlc"`
+	Origin string `json:"origin,omitempty"`
+}
+
+// Compare returns -1, 0 or +1 as s is before, equal to or after o.
+func (s Stamp) Compare(o Stamp) int {
+	if c := s.Clock.Compare(o.Clock); c != 0 {
+		return c
+	}
+	return strings.Compare(s.Origin, o.Origin)
+}
+
+// stampKey identifies an entry across dictionaries for the stamps.
+func stampKey(dict, id string) string {
+	return dictionaryName(dict) + "\u0000" + id
+}
+
+// stampEvent stamps the event as recorded by origin and records it as the
+// last write to the entries it touches.
+func stampEvent(state *dbState, event *JournalEvent, origin string) {
+	state.Clock = state.Clock.Tick()
+	event.Clock = state.Clock
+	event.Origin = origin
+	for _, id := range eventEntryIDs(*event) {
+		state.Stamps[stampKey(event.Dict, id)] = event.stamp()
+	}
+}
+
+// eventEntryIDs returns the entries the event writes: the entry itself,
+// and the one it replaces when an update changes the POS.
+func eventEntryIDs(event JournalEvent) []string {
+	id := gimedic.EntryID(event.Key, event.Value, event.Pos)
+	if event.Op == "update" && event.Prev != nil && event.Prev.ID() != id {
+		return []string{event.Prev.ID(), id}
+	}
+	return []string{id}
+}
+
+// accept reports whether a write stamped stamp to the entry wins over
+// the last one applied, recording it when it does.
+func (s *dbState) accept(dict, id string, stamp Stamp) bool {
+	if !s.wins(dict, id, stamp) {
+		return false
+	}
+	s.Stamps[stampKey(dict, id)] = stamp
+	return true
+}
+
+// observe advances the clock past the event.
+func (s *dbState) observe(event JournalEvent) {
+	if event.Clock != (HLC{}) {
+		s.Clock = s.Clock.Observe(event.Clock)
+	}
+}
+
+// wins is accept without recording the write. A write carrying the stamp
+// already recorded is the same write read again, as when a journal is
+// re-read from the start, and does not win; writes without a clock all
+// share the zero stamp and win on a tie, so they apply as they come.
+func (s *dbState) wins(dict, id string, stamp Stamp) bool {
+	last, ok := s.Stamps[stampKey(dict, id)]
+	if !ok {
+		return true
+	}
+	c := stamp.Compare(last)
+	return c > 0 || c == 0 && stamp.Clock == (HLC{})
+}
+
+// applyStampedEvent applies a pulled event by the last-writer-wins rule:
+// every entry, identified by dictionary name and gimedic.EntryID, takes
+// the write with the highest stamp, whether it adds, updates or deletes
+// the entry.
+// An update changing the POS writes both the entry it replaces, which it
+// deletes, and the new one. Events without a clock were recorded before
+// clocks existed; they lose to every stamped write and are applied as
+// they come otherwise.
+func applyStampedEvent(storage *gimedic.UserDictionaryStorage, state *dbState, event JournalEvent) bool {
+	state.observe(event)
+	stamp := event.stamp()
+	ids := eventEntryIDs(event)
+	if event.Clock == (HLC{}) {
+		for _, id := range ids {
+			if !state.accept(event.Dict, id, stamp) {
+				return false
+			}
+		}
+		return ApplyEvent(storage, event)
+	}
+	changed := false
+	if len(ids) > 1 && state.accept(event.Dict, ids[0], stamp) {
+		if dict := findDictionary(storage, event.Dict); dict != nil {
+			changed = deleteEntry(dict, ids[0])
+		}
+	}
+	id := ids[len(ids)-1]
+	if !state.accept(event.Dict, id, stamp) {
+		return changed
+	}
+	if event.Op == "delete" {
+		if dict := findDictionary(storage, event.Dict); dict != nil {
+			return deleteEntry(dict, id) || changed
+		}
+		return changed
+	}
+	dict := EnsureDictionary(storage, event.Dict)
+	if entry := findEntry(dict, id); entry != nil {
+		return updateEntry(entry, event) || changed
+	}
+	return addEntry(dict, event)
+}
+
+func findDictionary(storage *gimedic.UserDictionaryStorage, name string) *gimedic.UserDictionary {
+	name = dictionaryName(name)
+	for _, dict := range storage.GetDictionaries() {
+		if dictionaryName(dict.GetName()) == name {
+			return dict
+		}
+	}
+	return nil
+}
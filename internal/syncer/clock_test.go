@@ -0,0 +1,96 @@
+package syncer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestHLC(t *testing.T) {
+	orig := getNow
+	t.Cleanup(func() { getNow = orig })
+	now := time.UnixMilli(1000)
+	getNow = func() time.Time { return now }
+
+	c := HLC{}.Tick()
+	if c != (HLC{Wall: 1000}) {
+		t.Fatalf("Tick: %v", c)
+	}
+	if c = c.Tick(); c != (HLC{Wall: 1000, Logical: 1}) {
+		t.Fatalf("Tick within a millisecond: %v", c)
+	}
+	// A remote clock running ahead pushes the local one forward.
+	if c = c.Observe(HLC{Wall: 5000, Logical: 3}); c != (HLC{Wall: 5000, Logical: 4}) {
+		t.Fatalf("Observe: %v", c)
+	}
+	now = time.UnixMilli(2000)
+	if c = c.Tick(); c != (HLC{Wall: 5000, Logical: 5}) {
+		t.Fatalf("Tick behind the clock: %v", c)
+	}
+	now = time.UnixMilli(6000)
+	if c = c.Observe(HLC{Wall: 10}); c != (HLC{Wall: 6000}) {
+		t.Fatalf("Observe an old reading: %v", c)
+	}
+}
+
+func TestApplyStampedEventConverges(t *testing.T) {
+	at := func(wall int64, origin string, event JournalEvent) JournalEvent {
+		event.Dict, event.Clock, event.Origin = "main", HLC{Wall: wall}, origin
+		return event
+	}
+	events := []JournalEvent{
+		at(1, "a", JournalEvent{Op: "add", Key: "k", Value: "v", Pos: 1, Comment: "a1"}),
+		at(3, "b", JournalEvent{Op: "update", Key: "k", Value: "v", Pos: 1, Comment: "b3",
+			Prev: &EntryState{Key: "k", Value: "v", Pos: 1, Comment: "a1"}}),
+		at(3, "a", JournalEvent{Op: "update", Key: "k", Value: "v", Pos: 1, Comment: "a3",
+			Prev: &EntryState{Key: "k", Value: "v", Pos: 1, Comment: "a1"}}),
+		at(2, "a", JournalEvent{Op: "add", Key: "x", Value: "y", Pos: 1}),
+		at(4, "b", JournalEvent{Op: "delete", Key: "x", Value: "y", Pos: 1}),
+		at(5, "a", JournalEvent{Op: "update", Key: "p", Value: "q", Pos: 2,
+			Prev: &EntryState{Key: "p", Value: "q", Pos: 1}}),
+		at(2, "b", JournalEvent{Op: "add", Key: "p", Value: "q", Pos: 1, Comment: "old"}),
+	}
+	apply := func(order []int) Snapshot {
+		storage := &gimedic.UserDictionaryStorage{}
+		state := dbState{Stamps: map[string]Stamp{}}
+		for _, i := range order {
+			applyStampedEvent(storage, &state, events[i])
+		}
+		return SnapshotFromStorage(storage)
+	}
+	want := apply([]int{0, 1, 2, 3, 4, 5, 6})
+	for _, order := range [][]int{
+		{6, 5, 4, 3, 2, 1, 0},
+		{2, 0, 4, 1, 6, 3, 5},
+		{5, 6, 1, 2, 0, 4, 3},
+	} {
+		if got := apply(order); !reflect.DeepEqual(got, want) {
+			t.Fatalf("order %v: got %v, want %v", order, got, want)
+		}
+	}
+	entries := want.Dictionaries["main"]
+	if len(entries) != 2 {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	if got := entries[gimedic.EntryID("k", "v", 1)].Comment; got != "b3" {
+		t.Fatalf("tie not broken by origin: %q", got)
+	}
+	if _, ok := entries[gimedic.EntryID("p", "q", 2)]; !ok {
+		t.Fatalf("POS change lost: %v", entries)
+	}
+}
+
+func TestApplyStampedEventLegacy(t *testing.T) {
+	storage := &gimedic.UserDictionaryStorage{}
+	state := dbState{Stamps: map[string]Stamp{}}
+	applyStampedEvent(storage, &state, JournalEvent{Op: "add", Dict: "main", Key: "k", Value: "v", Pos: 1, Comment: "new",
+		Clock: HLC{Wall: 1}, Origin: "a"})
+	if applyStampedEvent(storage, &state, JournalEvent{Op: "delete", Dict: "main", Key: "k", Value: "v", Pos: 1}) {
+		t.Fatal("event without a clock won over a stamped write")
+	}
+	if !applyStampedEvent(storage, &state, JournalEvent{Op: "add", Dict: "main", Key: "o", Value: "v", Pos: 1}) {
+		t.Fatal("event without a clock not applied")
+	}
+}
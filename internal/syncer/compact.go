@@ -0,0 +1,212 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kyoh86/gimedic"
+)
+
+// JournalFormat is the journal file format a compacted journal declares
+// in its header.
+const JournalFormat = 1
+
+// journalHeader is the first record of a compacted journal. Its ID
+// changes with every compaction, telling pulling peers that their offset
+// into the journal no longer holds.
+type journalHeader struct {
+	Format  int    `json:"gimedic_journal"`
+	ID      string `json:"id"`
+	Created string `json:"created,omitempty"`
+}
+
+// parseJournalHeader reports whether the line is a journal header.
+func parseJournalHeader(line []byte) (journalHeader, bool, error) {
+	var header journalHeader
+	if !bytes.Contains(line, []byte(`"gimedic_journal"`)) {
+		return header, false, nil
+	}
+	if err := json.Unmarshal(line, &header); err != nil || header.Format == 0 {
+		return header, false, nil
+	}
+	if header.Format > JournalFormat {
+		return header, true, fmt.Errorf("journal format %d is newer than this gimedic supports (%d)", header.Format, JournalFormat)
+	}
+	return header, true, nil
+}
+
+// IsJournalHeader reports whether the journal line is a header rather
+// than an event.
+func IsJournalHeader(line []byte) bool {
+	_, ok, _ := parseJournalHeader(line)
+	return ok
+}
+
+// errJournalChanged tells that the journal grew while it was compacted.
+var errJournalChanged = errors.New("journal changed while compacting; nothing written, try again")
+
+// CompactStats tells what CompactJournal did.
+type CompactStats struct {
+	Before            int
+	After             int
+	DroppedTombstones int
+}
+
+// CompactJournal rewrites the journal into a checkpoint: for every entry
+// it writes only the last event, as an add holding the final state or a
+// delete. Deletes stamped longer than tombstoneTTL ago are dropped, on
+// the assumption that every peer has pulled them since; a peer that has
+// not brings the entry back. Events keep their stamps, by which peers
+// recognise what they pulled before and neither apply it again nor take
+// it for a conflict. The checkpoint
+// starts with a header whose new id makes peers re-read the journal from
+// the start. No lock keeps push from appending meanwhile, so the journal
+// is left alone when it changed while being compacted.
+func CompactJournal(path string, tombstoneTTL time.Duration) (CompactStats, error) {
+	var stats CompactStats
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return stats, err
+	}
+	type write struct {
+		index int
+		event JournalEvent
+	}
+	last := map[string]write{}
+	index := 0
+	record := func(event JournalEvent) {
+		last[stampKey(event.Dict, gimedic.EntryID(event.Key, event.Value, event.Pos))] = write{index: index, event: event}
+		index++
+	}
+	// soleAdd finds the entry a legacy update changes the POS of: the
+	// only one with its key and value, as ApplyEvent does.
+	soleAdd := func(event JournalEvent) *EntryState {
+		if _, ok := last[stampKey(event.Dict, gimedic.EntryID(event.Key, event.Value, event.Pos))]; ok {
+			return nil
+		}
+		var found *EntryState
+		for _, w := range last {
+			e := w.event
+			if e.Op == "add" && dictionaryName(e.Dict) == dictionaryName(event.Dict) && e.Key == event.Key && e.Value == event.Value {
+				if found != nil {
+					return nil
+				}
+				found = &EntryState{Key: e.Key, Value: e.Value, Comment: e.Comment, Locale: e.Locale, Pos: e.Pos}
+			}
+		}
+		return found
+	}
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if _, ok, err := parseJournalHeader(line); err != nil {
+			return stats, err
+		} else if ok {
+			continue
+		}
+		var event JournalEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return stats, err
+		}
+		stats.Before++
+		var replaced *EntryState
+		switch {
+		case event.Op != "update":
+		case event.Prev != nil && event.Prev.ID() != gimedic.EntryID(event.Key, event.Value, event.Pos):
+			replaced = event.Prev
+		case event.Prev == nil:
+			replaced = soleAdd(event)
+		}
+		if replaced != nil {
+			deleted := newEvent("delete", event.Dict, *replaced)
+			deleted.Timestamp, deleted.Clock, deleted.Origin = event.Timestamp, event.Clock, event.Origin
+			record(deleted)
+		}
+		if event.Op != "delete" {
+			event.Op = "add"
+		}
+		event.Prev = nil
+		record(event)
+	}
+
+	writes := make([]write, 0, len(last))
+	expiry := getNow().Add(-tombstoneTTL).UnixMilli()
+	for _, w := range last {
+		if at, ok := eventTime(w.event); ok && w.event.Op == "delete" && at < expiry {
+			stats.DroppedTombstones++
+			continue
+		}
+		writes = append(writes, w)
+	}
+	sort.Slice(writes, func(i, j int) bool { return writes[i].index < writes[j].index })
+
+	var buf bytes.Buffer
+	header, err := json.Marshal(journalHeader{
+		Format:  JournalFormat,
+		ID:      newJournalID(),
+		Created: getNow().UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return stats, err
+	}
+	buf.Write(append(header, '\n'))
+	for _, w := range writes {
+		data, err := json.Marshal(w.event)
+		if err != nil {
+			return stats, err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	stats.After = len(writes)
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return stats, err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(buf.Bytes()); err != nil {
+		temp.Close()
+		return stats, err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return stats, err
+	}
+	if err := temp.Close(); err != nil {
+		return stats, err
+	}
+	// A push appending after the journal was read would be lost.
+	info, err := os.Stat(path)
+	if err != nil {
+		return stats, err
+	}
+	if info.Size() != int64(len(raw)) {
+		return stats, errJournalChanged
+	}
+	return stats, os.Rename(temp.Name(), path)
+}
+
+func newJournalID() string {
+	return fmt.Sprintf("%016x", randomUint64())
+}
+
+// eventTime returns when the event was recorded in Unix milliseconds, or
+// false when it does not tell.
+func eventTime(event JournalEvent) (int64, bool) {
+	if event.Clock != (HLC{}) {
+		return event.Clock.Wall, true
+	}
+	ts, err := time.Parse(time.RFC3339Nano, event.Timestamp)
+	if err != nil {
+		return 0, false
+	}
+	return ts.UnixMilli(), true
+}
@@ -0,0 +1,203 @@
+package syncer
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestCompactJournal(t *testing.T) {
+	orig := getNow
+	t.Cleanup(func() { getNow = orig })
+	now := time.UnixMilli(100 * 24 * time.Hour.Milliseconds())
+	getNow = func() time.Time { return now }
+	day := 24 * time.Hour.Milliseconds()
+	recent, old := now.UnixMilli()-day, now.UnixMilli()-60*day
+
+	stamped := func(wall int64, event JournalEvent) JournalEvent {
+		event.Dict, event.Clock, event.Origin = "main", HLC{Wall: wall}, "a"
+		return event
+	}
+	events := []JournalEvent{
+		stamped(old, JournalEvent{Op: "add", Key: "k", Value: "v", Pos: 1, Comment: "c1"}),
+		stamped(old+1, JournalEvent{Op: "add", Key: "gone", Value: "g", Pos: 1}),
+		stamped(old+2, JournalEvent{Op: "delete", Key: "gone", Value: "g", Pos: 1}),
+		stamped(recent, JournalEvent{Op: "update", Key: "k", Value: "v", Pos: 1, Comment: "c2",
+			Prev: &EntryState{Key: "k", Value: "v", Pos: 1, Comment: "c1"}}),
+		stamped(recent+1, JournalEvent{Op: "add", Key: "x", Value: "y", Pos: 1}),
+		stamped(recent+2, JournalEvent{Op: "delete", Key: "x", Value: "y", Pos: 1}),
+		stamped(recent+3, JournalEvent{Op: "add", Key: "p", Value: "q", Pos: 1}),
+		stamped(recent+4, JournalEvent{Op: "update", Key: "p", Value: "q", Pos: 2,
+			Prev: &EntryState{Key: "p", Value: "q", Pos: 1}}),
+		{Op: "add", Dict: "main", Key: "l", Value: "m", Pos: 1},
+		{Op: "update", Dict: "main", Key: "l", Value: "m", Pos: 2},
+	}
+	var lines []string
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		lines = append(lines, string(data))
+	}
+	path := t.TempDir() + "/self.jsonl"
+	if err := os.WriteFile(path, []byte(joinLines(lines)), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	dbPath := t.TempDir() + "/user_dictionary.db"
+	replay := func() Snapshot {
+		t.Helper()
+		if err := WriteStorage(dbPath, emptyStorage()); err != nil {
+			t.Fatalf("WriteStorage: %v", err)
+		}
+		state := dbState{Stamps: map[string]Stamp{}}
+		if _, _, _, err := applyJournal(dbPath, path, 0, func(storage *gimedic.UserDictionaryStorage, event JournalEvent) bool {
+			return applyStampedEvent(storage, &state, event)
+		}, nil); err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+		storage, err := LoadStorage(dbPath)
+		if err != nil {
+			t.Fatalf("LoadStorage: %v", err)
+		}
+		return SnapshotFromStorage(storage)
+	}
+	want := replay()
+
+	stats, err := CompactJournal(path, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("CompactJournal: %v", err)
+	}
+	if stats != (CompactStats{Before: 10, After: 6, DroppedTombstones: 1}) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if got := replay(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("checkpoint replays to %v, want %v", got, want)
+	}
+	id, _, err := journalFingerprint(path)
+	if err != nil || id == "" || strings.HasPrefix(id, headFingerprintPrefix) {
+		t.Fatalf("journalFingerprint: %q, %v", id, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	if !strings.HasPrefix(string(data), `{"gimedic_journal":1,`) {
+		t.Fatalf("missing header: %s", data)
+	}
+
+	if _, err := CompactJournal(path, 30*24*time.Hour); err != nil {
+		t.Fatalf("CompactJournal again: %v", err)
+	}
+	if again, _, _ := journalFingerprint(path); again == id {
+		t.Fatal("compaction kept the journal id")
+	}
+}
+
+func TestPullRebasesCompactedJournal(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	dbPath := dir + "/user_dictionary.db"
+	if err := WriteStorage(dbPath, emptyStorage()); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	other := dir + "/other.jsonl"
+	var journal []string
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		journal = append(journal, `{"op":"add","dict":"main","key":"`+key+`","value":"v","pos":1,"hlc":{"wall":1,"logical":0},"origin":"b"}`)
+	}
+	journal = append(journal, `{"op":"delete","dict":"main","key":"k1","value":"v","pos":1,"hlc":{"wall":2,"logical":0},"origin":"b"}`)
+	if err := os.WriteFile(other, []byte(joinLines(journal)), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	service := Service{DBPath: dbPath, JournalDir: dir, Origin: "a"}
+	if _, err := service.Pull([]string{other}); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+
+	if _, err := CompactJournal(other, 0); err != nil {
+		t.Fatalf("CompactJournal: %v", err)
+	}
+	// The peer adds an entry after compacting, leaving the journal shorter
+	// than the offset saved before.
+	f, err := os.OpenFile(other, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	f.WriteString(`{"op":"add","dict":"main","key":"k5","value":"v","pos":1,"hlc":{"wall":3,"logical":0},"origin":"b"}` + "\n")
+	f.Close()
+
+	if _, err := service.Pull([]string{other}); err != nil {
+		t.Fatalf("Pull after compaction: %v", err)
+	}
+	storage, err := LoadStorage(dbPath)
+	if err != nil {
+		t.Fatalf("LoadStorage: %v", err)
+	}
+	var keys []string
+	for _, entry := range storage.GetDictionaries()[0].GetEntries() {
+		keys = append(keys, entry.GetKey())
+	}
+	if want := []string{"k2", "k3", "k4", "k5"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("got %v, want %v", keys, want)
+	}
+}
+
+func TestPullCompactedJournalKeepsLocalEdits(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	dbPath := dir + "/user_dictionary.db"
+	if err := WriteStorage(dbPath, emptyStorage()); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	other := dir + "/other.jsonl"
+	journal := []string{
+		`{"op":"add","dict":"main","key":"k1","value":"v","pos":1,"hlc":{"wall":1,"logical":0},"origin":"b"}`,
+		`{"op":"add","dict":"main","key":"k2","value":"v","pos":1,"hlc":{"wall":2,"logical":0},"origin":"b"}`,
+		`{"op":"delete","dict":"main","key":"k1","value":"v","pos":1,"hlc":{"wall":3,"logical":0},"origin":"b"}`,
+	}
+	if err := os.WriteFile(other, []byte(joinLines(journal)), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	service := Service{DBPath: dbPath, JournalDir: dir, Origin: "a"}
+	if _, err := service.Pull([]string{other}); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+
+	// Edit k2 locally without pushing, while the peer compacts.
+	storage, err := LoadStorage(dbPath)
+	if err != nil {
+		t.Fatalf("LoadStorage: %v", err)
+	}
+	ApplyEvent(storage, JournalEvent{Op: "update", Dict: "main", Key: "k2", Value: "v", Pos: 1, Comment: "local"})
+	if err := WriteStorage(dbPath, storage); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	if _, err := CompactJournal(other, 0); err != nil {
+		t.Fatalf("CompactJournal: %v", err)
+	}
+
+	applied, err := service.Pull([]string{other})
+	if err != nil {
+		t.Fatalf("Pull after compaction: %v", err)
+	}
+	if applied != 0 {
+		t.Fatalf("events pulled before applied again: %d", applied)
+	}
+	if conflicts, _ := LoadConflicts(dbPath); len(conflicts) != 0 {
+		t.Fatalf("events pulled before taken for conflicts: %#v", conflicts)
+	}
+	storage, err = LoadStorage(dbPath)
+	if err != nil {
+		t.Fatalf("LoadStorage: %v", err)
+	}
+	entries := storage.GetDictionaries()[0].GetEntries()
+	if len(entries) != 1 || entries[0].GetComment() != "local" {
+		t.Fatalf("local edit lost: %v", entries)
+	}
+}
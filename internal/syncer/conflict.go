@@ -0,0 +1,310 @@
+package syncer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kyoh86/gimedic"
+)
+
+// Conflict is a pulled event that would have overwritten entries changed
+// locally since the last sync. The event is left unapplied and the
+// entries keep their local state until the conflict is resolved.
+type Conflict struct {
+	ID       string          `json:"id"`
+	Detected string          `json:"detected"`
+	Journal  string          `json:"journal"`
+	Dict     string          `json:"dict"`
+	Entries  []ConflictEntry `json:"entries"`
+	Remote   JournalEvent    `json:"remote"`
+}
+
+// ConflictEntry is an entry the remote event writes, as of the last sync,
+// as changed locally and as the event would leave it. A nil state means
+// the entry is absent.
+type ConflictEntry struct {
+	ID     string      `json:"id"`
+	Base   *EntryState `json:"base,omitempty"`
+	Local  *EntryState `json:"local,omitempty"`
+	Remote *EntryState `json:"remote,omitempty"`
+}
+
+// Changed reports whether the entry was changed locally.
+func (e ConflictEntry) Changed() bool {
+	return !sameEntryState(e.Base, e.Local)
+}
+
+func sameEntryState(a, b *EntryState) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return entryStateEqual(*a, *b)
+}
+
+func dictionaryName(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return name
+}
+
+// remoteEntryStates returns the states the event leaves the entries it
+// writes in, keyed by gimedic.EntryID.
+func remoteEntryStates(event JournalEvent) map[string]*EntryState {
+	states := map[string]*EntryState{}
+	ids := eventEntryIDs(event)
+	for _, id := range ids {
+		states[id] = nil
+	}
+	if event.Op != "delete" {
+		states[ids[len(ids)-1]] = &EntryState{Key: event.Key, Value: event.Value, Comment: event.Comment, Locale: event.Locale, Pos: event.Pos}
+	}
+	return states
+}
+
+// detectConflict returns the conflict the event makes with local changes:
+// an entry it would write that differs both from base, the dictionary as
+// of the last sync, and from what the event writes. Writes that lose by
+// the last-writer-wins rule are not applied and make no conflict.
+func detectConflict(storage *gimedic.UserDictionaryStorage, base Snapshot, state *dbState, journalPath string, event JournalEvent) *Conflict {
+	dict := dictionaryName(event.Dict)
+	remote := remoteEntryStates(event)
+	conflicting := false
+	var entries []ConflictEntry
+	for _, id := range eventEntryIDs(event) {
+		entry := ConflictEntry{ID: id, Local: findEntryState(storage, dict, id), Remote: remote[id]}
+		if b, ok := base.Dictionaries[dict][id]; ok {
+			entry.Base = &b
+		}
+		if entry.Changed() && !sameEntryState(entry.Local, entry.Remote) && state.wins(event.Dict, id, event.stamp()) {
+			conflicting = true
+		}
+		entries = append(entries, entry)
+	}
+	if !conflicting {
+		return nil
+	}
+	raw, _ := json.Marshal(event)
+	sum := sha256.Sum256(append([]byte(filepath.Base(journalPath)+"\u0000"), raw...))
+	return &Conflict{
+		ID:       hex.EncodeToString(sum[:4]),
+		Detected: getNow().UTC().Format(time.RFC3339),
+		Journal:  journalPath,
+		Dict:     dict,
+		Entries:  entries,
+		Remote:   event,
+	}
+}
+
+// updateBase brings the entries in base up to date with storage, so that
+// pulled changes are not taken for local ones.
+func updateBase(storage *gimedic.UserDictionaryStorage, base Snapshot, dict string, ids []string) {
+	dict = dictionaryName(dict)
+	entries := base.Dictionaries[dict]
+	if entries == nil {
+		entries = map[string]EntryState{}
+		base.Dictionaries[dict] = entries
+	}
+	for _, id := range ids {
+		if state := findEntryState(storage, dict, id); state != nil {
+			entries[id] = *state
+		} else {
+			delete(entries, id)
+		}
+	}
+}
+
+func conflictsPath(dbPath string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(dbPath))
+	return filepath.Join(dir, "conflicts_"+hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// LoadConflicts returns the unresolved conflicts of the dictionary file.
+func LoadConflicts(dbPath string) ([]Conflict, error) {
+	path, err := conflictsPath(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var conflicts []Conflict
+	if err := json.Unmarshal(data, &conflicts); err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+func saveConflicts(dbPath string, conflicts []Conflict) error {
+	path, err := conflictsPath(dbPath)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(conflicts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// addConflicts records new conflicts, ignoring ones already recorded.
+func addConflicts(dbPath string, found []Conflict) error {
+	if len(found) == 0 {
+		return nil
+	}
+	conflicts, err := LoadConflicts(dbPath)
+	if err != nil {
+		return err
+	}
+	known := map[string]struct{}{}
+	for _, c := range conflicts {
+		known[c.ID] = struct{}{}
+	}
+	for _, c := range found {
+		if _, ok := known[c.ID]; !ok {
+			conflicts = append(conflicts, c)
+			known[c.ID] = struct{}{}
+		}
+	}
+	return saveConflicts(dbPath, conflicts)
+}
+
+// ResolveConflict settles the conflict by replacing the entries it
+// touches with want, an empty want deleting them all. The dictionary is
+// written and the result is appended to the own journal as new events,
+// which win over the conflicting ones on every peer; when the append
+// fails, the dictionary is put back. It returns the number of events
+// appended.
+func (s Service) ResolveConflict(id string, want []EntryState) (int, error) {
+	conflicts, err := LoadConflicts(s.DBPath)
+	if err != nil {
+		return 0, err
+	}
+	index := -1
+	for i, c := range conflicts {
+		if c.ID == id {
+			index = i
+		}
+	}
+	if index < 0 {
+		return 0, fmt.Errorf("conflict %q not found", id)
+	}
+	conflict := conflicts[index]
+
+	storage, err := LoadStorage(s.DBPath)
+	if err != nil {
+		return 0, err
+	}
+	wanted := map[string]struct{}{}
+	for _, state := range want {
+		wanted[state.ID()] = struct{}{}
+	}
+	var events []JournalEvent
+	for _, entry := range conflict.Entries {
+		if _, ok := wanted[entry.ID]; ok {
+			continue
+		}
+		for _, state := range []*EntryState{entry.Local, entry.Remote, entry.Base} {
+			if state != nil {
+				events = append(events, newEvent("delete", conflict.Dict, *state))
+				break
+			}
+		}
+	}
+	for _, state := range want {
+		current := findEntryState(storage, conflict.Dict, state.ID())
+		if current == nil {
+			events = append(events, newEvent("add", conflict.Dict, state))
+			continue
+		}
+		event := newEvent("update", conflict.Dict, state)
+		event.Prev = current
+		events = append(events, event)
+	}
+	journalPath, err := s.OwnJournalPath()
+	if err != nil {
+		return 0, err
+	}
+	clockPath, err := dbStatePath(s.DBPath)
+	if err != nil {
+		return 0, err
+	}
+	clocks, err := loadDBState(clockPath)
+	if err != nil {
+		return 0, err
+	}
+	origin := s.origin()
+	for i := range events {
+		stampEvent(&clocks, &events[i], origin)
+	}
+
+	// The dictionary must not keep a resolution the peers do not get.
+	original, err := os.ReadFile(s.DBPath)
+	if err != nil {
+		return 0, err
+	}
+	for _, event := range events {
+		ApplyEvent(storage, event)
+	}
+	if err := WriteStorage(s.DBPath, storage); err != nil {
+		return 0, err
+	}
+	if err := AppendJournalEvents(journalPath, events); err != nil {
+		if restoreErr := os.WriteFile(s.DBPath, original, 0o644); restoreErr != nil {
+			return 0, errors.Join(err, restoreErr)
+		}
+		return 0, err
+	}
+	if err := saveDBState(clockPath, clocks); err != nil {
+		return 0, err
+	}
+
+	// The own snapshot takes the result, so that push does not record it
+	// again.
+	statePath, err := SyncStatePath(s.DBPath, journalPath)
+	if err != nil {
+		return 0, err
+	}
+	state, err := LoadSyncState(statePath)
+	if err != nil {
+		return 0, err
+	}
+	if state.Snapshot.Dictionaries == nil {
+		state.Snapshot.Dictionaries = map[string]map[string]EntryState{}
+	}
+	for _, event := range events {
+		updateBase(storage, state.Snapshot, event.Dict, eventEntryIDs(event))
+	}
+	if err := SaveSyncState(statePath, state); err != nil {
+		return 0, err
+	}
+
+	conflicts = append(conflicts[:index], conflicts[index+1:]...)
+	if err := saveConflicts(s.DBPath, conflicts); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
@@ -0,0 +1,165 @@
+package syncer
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPullDetectsAndResolvesConflicts(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	dbPath := dir + "/user_dictionary.db"
+	service := Service{DBPath: dbPath, JournalDir: dir, Origin: "peer-a"}
+	ownJournal, err := service.OwnJournalPath()
+	if err != nil {
+		t.Fatalf("OwnJournalPath: %v", err)
+	}
+	storage := storageWithEntry("main", "k1", "v1")
+	ApplyEvent(storage, JournalEvent{Op: "add", Dict: "main", Key: "k2", Value: "v2", Pos: 1})
+	if err := WriteStorage(dbPath, storage); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	if _, err := service.Push(ownJournal); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	// Change k1 locally, while peer-b changes both entries.
+	ApplyEvent(storage, JournalEvent{Op: "update", Dict: "main", Key: "k1", Value: "v1", Pos: 1, Comment: "local"})
+	if err := WriteStorage(dbPath, storage); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	other := dir + "/other.jsonl"
+	lines := `{"op":"update","dict":"main","key":"k2","value":"v2","pos":1,"comment":"remote","hlc":{"wall":9999999999998,"logical":0},"origin":"peer-b"}` + "\n" +
+		`{"op":"update","dict":"main","key":"k1","value":"v1","pos":1,"comment":"remote","hlc":{"wall":9999999999999,"logical":0},"origin":"peer-b"}` + "\n"
+	if err := os.WriteFile(other, []byte(lines), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	applied, err := service.Pull([]string{other})
+	if err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if applied != 1 {
+		t.Fatalf("expected only the unconflicting event applied, got %d", applied)
+	}
+	comments := func() map[string]string {
+		t.Helper()
+		storage, err := LoadStorage(dbPath)
+		if err != nil {
+			t.Fatalf("LoadStorage: %v", err)
+		}
+		got := map[string]string{}
+		for _, entry := range storage.GetDictionaries()[0].GetEntries() {
+			got[entry.GetKey()] = entry.GetComment()
+		}
+		return got
+	}
+	if got := comments(); got["k1"] != "local" || got["k2"] != "remote" {
+		t.Fatalf("unexpected entries after pull: %v", got)
+	}
+	conflicts, err := LoadConflicts(dbPath)
+	if err != nil {
+		t.Fatalf("LoadConflicts: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected one conflict, got %#v", conflicts)
+	}
+	entry := conflicts[0].Entries[0]
+	if entry.Base.Comment != "" || entry.Local.Comment != "local" || entry.Remote.Comment != "remote" {
+		t.Fatalf("unexpected conflict entry: %#v", entry)
+	}
+
+	// Pulling again records nothing new.
+	state, _ := LoadSyncState(mustSyncStatePath(t, dbPath, other))
+	state.JournalOffset = 0
+	if err := SaveSyncState(mustSyncStatePath(t, dbPath, other), state); err != nil {
+		t.Fatalf("SaveSyncState: %v", err)
+	}
+	if _, err := service.Pull([]string{other}); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if conflicts, _ := LoadConflicts(dbPath); len(conflicts) != 1 {
+		t.Fatalf("conflict recorded twice: %#v", conflicts)
+	}
+
+	wrote, err := service.ResolveConflict(conflicts[0].ID, []EntryState{*entry.Remote})
+	if err != nil {
+		t.Fatalf("ResolveConflict: %v", err)
+	}
+	if wrote != 1 {
+		t.Fatalf("expected one event pushed, got %d", wrote)
+	}
+	if got := comments(); got["k1"] != "remote" {
+		t.Fatalf("unexpected entries after resolve: %v", got)
+	}
+	data, err := os.ReadFile(ownJournal)
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	journal := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var resolution JournalEvent
+	if err := json.Unmarshal([]byte(journal[len(journal)-1]), &resolution); err != nil {
+		t.Fatalf("unmarshal event: %v", err)
+	}
+	if resolution.stamp().Compare(conflicts[0].Remote.stamp()) <= 0 {
+		t.Fatalf("resolution does not win over the conflicting event: %v", resolution.Clock)
+	}
+	if conflicts, _ := LoadConflicts(dbPath); len(conflicts) != 0 {
+		t.Fatalf("conflict left: %#v", conflicts)
+	}
+	if wrote, err := service.Push(ownJournal); err != nil || wrote != 0 {
+		t.Fatalf("Push after resolve: wrote=%d err=%v", wrote, err)
+	}
+}
+
+func mustSyncStatePath(t *testing.T, dbPath, journalPath string) string {
+	t.Helper()
+	path, err := SyncStatePath(dbPath, journalPath)
+	if err != nil {
+		t.Fatalf("SyncStatePath: %v", err)
+	}
+	return path
+}
+
+func TestResolveConflictKeepsDBWhenJournalFails(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	dbPath := dir + "/user_dictionary.db"
+	service := Service{DBPath: dbPath, JournalDir: dir, Origin: "peer-a"}
+	if err := WriteStorage(dbPath, storageWithEntry("main", "k1", "v1")); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	before, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("read db: %v", err)
+	}
+	local := EntryState{Key: "k1", Value: "v1", Pos: 1}
+	remote := EntryState{Key: "k1", Value: "v1", Pos: 1, Comment: "remote"}
+	conflict := Conflict{ID: "c1", Dict: "main", Entries: []ConflictEntry{{ID: local.ID(), Local: &local, Remote: &remote}}}
+	if err := saveConflicts(dbPath, []Conflict{conflict}); err != nil {
+		t.Fatalf("saveConflicts: %v", err)
+	}
+	// A directory where the own journal should be makes the append fail.
+	ownJournal, err := service.OwnJournalPath()
+	if err != nil {
+		t.Fatalf("OwnJournalPath: %v", err)
+	}
+	if err := os.MkdirAll(ownJournal, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	if _, err := service.ResolveConflict("c1", []EntryState{remote}); err == nil {
+		t.Fatal("ResolveConflict succeeded without a journal")
+	}
+	after, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("read db: %v", err)
+	}
+	if string(after) != string(before) {
+		t.Fatal("dictionary changed although the resolution was not journaled")
+	}
+	if conflicts, _ := LoadConflicts(dbPath); len(conflicts) != 1 {
+		t.Fatalf("conflict dropped: %#v", conflicts)
+	}
+}
@@ -2,22 +2,30 @@ package syncer
 
 import "sort"
 
+// DiffSnapshots returns the events that turn before into after. An entry
+// whose POS alone changed is an update carrying the previous POS in Prev
+// when it is the only entry with its key and value on both sides;
+// otherwise entries of other POS are added and deleted on their own.
 func DiffSnapshots(before, after Snapshot) []JournalEvent {
 	events := []JournalEvent{}
 	for dictName, afterEntries := range after.Dictionaries {
 		beforeEntries := before.Dictionaries[dictName]
-		for key, afterEntry := range afterEntries {
-			if beforeEntry, ok := beforeEntries[key]; !ok {
-				events = append(events, newEvent("add", dictName, afterEntry))
+		var added, deleted []EntryState
+		for id, afterEntry := range afterEntries {
+			if beforeEntry, ok := beforeEntries[id]; !ok {
+				added = append(added, afterEntry)
 			} else if !entryStateEqual(beforeEntry, afterEntry) {
-				events = append(events, newEvent("update", dictName, afterEntry))
+				event := newEvent("update", dictName, afterEntry)
+				event.Prev = &beforeEntry
+				events = append(events, event)
 			}
 		}
-		for key, beforeEntry := range beforeEntries {
-			if _, ok := afterEntries[key]; !ok {
-				events = append(events, newEvent("delete", dictName, beforeEntry))
+		for id, beforeEntry := range beforeEntries {
+			if _, ok := afterEntries[id]; !ok {
+				deleted = append(deleted, beforeEntry)
 			}
 		}
+		events = append(events, pairPosChanges(dictName, added, deleted)...)
 	}
 	for dictName, beforeEntries := range before.Dictionaries {
 		if _, ok := after.Dictionaries[dictName]; ok {
@@ -28,14 +36,59 @@ func DiffSnapshots(before, after Snapshot) []JournalEvent {
 		}
 	}
 	sort.Slice(events, func(i, j int) bool {
-		if events[i].Dict == events[j].Dict {
-			return events[i].Key < events[j].Key
+		a, b := events[i], events[j]
+		switch {
+		case a.Dict != b.Dict:
+			return a.Dict < b.Dict
+		case a.Key != b.Key:
+			return a.Key < b.Key
+		case a.Value != b.Value:
+			return a.Value < b.Value
+		case a.Pos != b.Pos:
+			return a.Pos < b.Pos
 		}
-		return events[i].Dict < events[j].Dict
+		return a.Op < b.Op
 	})
 	return events
 }
 
+// pairPosChanges turns the added and deleted entries of a dictionary into
+// events, joining an addition and a deletion into an update when they are
+// the only ones for their key and value.
+func pairPosChanges(dictName string, added, deleted []EntryState) []JournalEvent {
+	wordOf := func(entry EntryState) string {
+		return entry.Key + "\u0000" + entry.Value
+	}
+	addedByWord := map[string][]EntryState{}
+	for _, entry := range added {
+		addedByWord[wordOf(entry)] = append(addedByWord[wordOf(entry)], entry)
+	}
+	deletedByWord := map[string][]EntryState{}
+	for _, entry := range deleted {
+		deletedByWord[wordOf(entry)] = append(deletedByWord[wordOf(entry)], entry)
+	}
+	events := []JournalEvent{}
+	for word, adds := range addedByWord {
+		dels := deletedByWord[word]
+		if len(adds) == 1 && len(dels) == 1 {
+			event := newEvent("update", dictName, adds[0])
+			event.Prev = &dels[0]
+			events = append(events, event)
+			delete(deletedByWord, word)
+			continue
+		}
+		for _, entry := range adds {
+			events = append(events, newEvent("add", dictName, entry))
+		}
+	}
+	for _, dels := range deletedByWord {
+		for _, entry := range dels {
+			events = append(events, newEvent("delete", dictName, entry))
+		}
+	}
+	return events
+}
+
 func newEvent(op, dict string, entry EntryState) JournalEvent {
 	return JournalEvent{
 		Op:      op,
@@ -40,3 +40,36 @@ func TestDiffSnapshots(t *testing.T) {
 		t.Fatalf("unexpected events: %#v", got)
 	}
 }
+
+func snapshotOf(dict string, entries ...EntryState) Snapshot {
+	snapshot := Snapshot{Dictionaries: map[string]map[string]EntryState{dict: {}}}
+	for _, entry := range entries {
+		snapshot.Dictionaries[dict][entry.ID()] = entry
+	}
+	return snapshot
+}
+
+func TestDiffSnapshotsHomographs(t *testing.T) {
+	noun := EntryState{Key: "はし", Value: "橋", Pos: 1}
+	verb := EntryState{Key: "はし", Value: "橋", Pos: 20}
+	events := DiffSnapshots(snapshotOf("A", noun), snapshotOf("A", noun, verb))
+	if len(events) != 1 || events[0].Op != "add" || events[0].Pos != 20 {
+		t.Fatalf("adding a homograph: %#v", events)
+	}
+
+	events = DiffSnapshots(snapshotOf("A", noun), snapshotOf("A", verb))
+	if len(events) != 1 || events[0].Op != "update" || events[0].Pos != 20 || events[0].Prev == nil || events[0].Prev.Pos != 1 {
+		t.Fatalf("changing the POS: %#v", events)
+	}
+
+	other := EntryState{Key: "はし", Value: "橋", Pos: 30}
+	events = DiffSnapshots(snapshotOf("A", noun, verb), snapshotOf("A", other))
+	if len(events) != 3 {
+		t.Fatalf("replacing homographs: %#v", events)
+	}
+	for _, event := range events {
+		if event.Op == "update" {
+			t.Fatalf("ambiguous POS change paired: %#v", events)
+		}
+	}
+}
@@ -3,9 +3,14 @@ package syncer
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/kyoh86/gimedic"
@@ -35,7 +40,17 @@ func AppendJournalEvents(path string, events []JournalEvent) error {
 	return file.Sync()
 }
 
+// ApplyJournal applies the events of the journal from offset on, returning
+// the offset to continue from. A malformed record fails the call.
 func ApplyJournal(dbPath, journalPath string, offset int64) (int, bool, int64, error) {
+	return applyJournal(dbPath, journalPath, offset, ApplyEvent, nil)
+}
+
+// applyJournal is ApplyJournal applying each event with apply. The offset
+// only advances over complete records, leaving a last line still being
+// written to the next call. Malformed records are passed to malformed
+// and skipped, or fail the call when malformed is nil.
+func applyJournal(dbPath, journalPath string, offset int64, apply func(*gimedic.UserDictionaryStorage, JournalEvent) bool, malformed func(offset int64, line []byte, err error) error) (int, bool, int64, error) {
 	file, err := os.Open(journalPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -49,7 +64,7 @@ func ApplyJournal(dbPath, journalPath string, offset int64) (int, bool, int64, e
 		return 0, false, offset, err
 	}
 
-	reader := bufio.NewScanner(file)
+	reader := bufio.NewReader(file)
 	changed := false
 	applied := 0
 
@@ -57,33 +72,94 @@ func ApplyJournal(dbPath, journalPath string, offset int64) (int, bool, int64, e
 	if err != nil {
 		return 0, false, offset, err
 	}
-	for reader.Scan() {
-		line := reader.Bytes()
-		if len(bytes.TrimSpace(line)) == 0 {
+	newOffset := offset
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, false, offset, err
+		}
+		recordOffset := newOffset
+		newOffset += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
 			continue
 		}
-		var event JournalEvent
-		if err := json.Unmarshal(line, &event); err != nil {
+		if _, ok, err := parseJournalHeader(line); err != nil {
 			return 0, false, offset, err
+		} else if ok {
+			continue
 		}
-		if ApplyEvent(storage, event) {
+		event, err := parseJournalEvent(line)
+		if err != nil {
+			if malformed == nil {
+				return 0, false, offset, err
+			}
+			if err := malformed(recordOffset, line, err); err != nil {
+				return 0, false, offset, err
+			}
+			continue
+		}
+		if apply(storage, event) {
 			changed = true
 			applied++
 		}
 	}
-	if err := reader.Err(); err != nil {
-		return 0, false, offset, err
-	}
 	if changed {
 		if err := WriteStorage(dbPath, storage); err != nil {
 			return 0, false, offset, err
 		}
 	}
-	newOffset, err := JournalSize(journalPath)
+	return applied, changed, newOffset, nil
+}
+
+// parseJournalEvent parses a journal line holding an event.
+func parseJournalEvent(line []byte) (JournalEvent, error) {
+	var event JournalEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return event, err
+	}
+	switch event.Op {
+	case "add", "update", "delete":
+		return event, nil
+	}
+	return event, fmt.Errorf("unknown op %q", event.Op)
+}
+
+// headFingerprintPrefix starts the fingerprint of journals without a
+// header.
+const headFingerprintPrefix = "head:"
+
+// journalFingerprint identifies the content of a journal: the id in its
+// header, or a hash of its first record for journals without one; "" for
+// a journal without a complete record. It returns the size as well.
+func journalFingerprint(path string) (string, int64, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return applied, changed, offset, err
+		if errors.Is(err, os.ErrNotExist) {
+			return "", 0, nil
+		}
+		return "", 0, err
 	}
-	return applied, changed, newOffset, nil
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		return "", info.Size(), nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if header, ok, _ := parseJournalHeader(bytes.TrimSpace(line)); ok {
+		return header.ID, info.Size(), nil
+	}
+	sum := sha256.Sum256(line)
+	return headFingerprintPrefix + hex.EncodeToString(sum[:8]), info.Size(), nil
 }
 
 func JournalSize(path string) (int64, error) {
@@ -97,19 +173,99 @@ func JournalSize(path string) (int64, error) {
 	return 0, err
 }
 
+// ApplyEvent applies the event to the storage and reports whether it
+// changed anything. Events identify entries by key, value and POS; an
+// update finds the entry it changes by its Prev when given. Updates
+// recorded before POS was part of the identity carry no Prev and change
+// the POS of the only entry with their key and value.
 func ApplyEvent(storage *gimedic.UserDictionaryStorage, event JournalEvent) bool {
-	dict := ensureDictionary(storage, event.Dict)
-	key := event.Key + "\u0000" + event.Value
-	for _, entry := range dict.GetEntries() {
-		if entry.GetKey()+"\u0000"+entry.GetValue() == key {
-			if event.Op == "delete" {
-				return deleteEntry(dict, key)
-			}
-			return updateEntry(entry, event)
+	dict := EnsureDictionary(storage, event.Dict)
+	id := gimedic.EntryID(event.Key, event.Value, event.Pos)
+	switch event.Op {
+	case "delete":
+		return deleteEntry(dict, id)
+	case "update":
+		target := findEntry(dict, event.targetID())
+		if target == nil && event.Prev == nil {
+			target = soleEntry(dict, event.Key, event.Value)
+		}
+		if target == nil {
+			return addEntry(dict, event)
+		}
+		if other := findEntry(dict, id); other != nil && other != target {
+			// The entry takes the POS of another one: they become one.
+			deleteEntry(dict, entryID(target))
+			updateEntry(other, event)
+			return true
 		}
+		return updateEntry(target, event)
 	}
-	if event.Op == "delete" {
-		return false
+	if entry := findEntry(dict, id); entry != nil {
+		return updateEntry(entry, event)
 	}
 	return addEntry(dict, event)
 }
+
+// QuarantinedRecord is a journal record Pull could not read.
+type QuarantinedRecord struct {
+	Detected string `json:"detected"`
+	Journal  string `json:"journal"`
+	// Fingerprint tells which content of the journal Offset points into;
+	// see journalFingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Offset      int64  `json:"offset"`
+	Error       string `json:"error"`
+	Line        string `json:"line"`
+}
+
+// QuarantinePath returns the file keeping the records of journals that
+// Pull skipped for the dictionary file.
+func QuarantinePath(dbPath string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(dbPath))
+	return filepath.Join(dir, "quarantine_"+hex.EncodeToString(sum[:])+".jsonl"), nil
+}
+
+// quarantineRecord appends the malformed record to the quarantine file,
+// returning its path. A record already kept from the same journal
+// content, met again when the journal is re-read, is not added again, and
+// added reports false.
+func quarantineRecord(dbPath string, record QuarantinedRecord) (path string, added bool, err error) {
+	path, err = QuarantinePath(dbPath)
+	if err != nil {
+		return "", false, err
+	}
+	if raw, err := os.ReadFile(path); err == nil {
+		for _, line := range bytes.Split(raw, []byte("\n")) {
+			var kept QuarantinedRecord
+			if json.Unmarshal(line, &kept) != nil {
+				continue
+			}
+			if kept.Journal == record.Journal && kept.Fingerprint == record.Fingerprint && kept.Offset == record.Offset {
+				return path, false, nil
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", false, err
+	}
+	record.Detected = getNow().UTC().Format(time.RFC3339)
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", false, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return "", false, err
+	}
+	return path, true, file.Close()
+}
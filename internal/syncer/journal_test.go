@@ -71,6 +71,75 @@ func TestApplyJournalOffset(t *testing.T) {
 	}
 }
 
+func TestApplyJournalPartialLine(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := dir + "/user_dictionary.db"
+	journalPath := dir + "/journal.jsonl"
+
+	if err := WriteStorage(dbPath, emptyStorage()); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	first := `{"op":"add","dict":"main","key":"k1","value":"v1","pos":1}` + "\n"
+	second := `{"op":"add","dict":"main","key":"k2","value":"v2","pos":1}`
+	if err := os.WriteFile(journalPath, []byte(first+second[:20]), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	applied, _, offset, err := ApplyJournal(dbPath, journalPath, 0)
+	if err != nil {
+		t.Fatalf("ApplyJournal: %v", err)
+	}
+	if applied != 1 || offset != int64(len(first)) {
+		t.Fatalf("unexpected apply result: applied=%d offset=%d", applied, offset)
+	}
+
+	if err := os.WriteFile(journalPath, []byte(first+second+"\n"), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	applied, _, offset, err = ApplyJournal(dbPath, journalPath, offset)
+	if err != nil {
+		t.Fatalf("ApplyJournal: %v", err)
+	}
+	if applied != 1 || offset != int64(len(first)+len(second)+1) {
+		t.Fatalf("unexpected apply result: applied=%d offset=%d", applied, offset)
+	}
+}
+
+func TestApplyJournalMalformed(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := dir + "/user_dictionary.db"
+	journalPath := dir + "/journal.jsonl"
+
+	if err := WriteStorage(dbPath, emptyStorage()); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	journal := []string{
+		`{"op":"add","dict":"main","key":"k1","value":"v1","pos":1}`,
+		`{"op":"add","dict":"main","key":`,
+		`{"op":"rename","dict":"main","key":"k1","value":"v1","pos":1}`,
+		`{"op":"add","dict":"main","key":"k2","value":"v2","pos":1}`,
+	}
+	if err := os.WriteFile(journalPath, []byte(joinLines(journal)), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	if _, _, _, err := ApplyJournal(dbPath, journalPath, 0); err == nil {
+		t.Fatalf("expected ApplyJournal to fail on a malformed record")
+	}
+
+	var skipped []int64
+	applied, _, _, err := applyJournal(dbPath, journalPath, 0, ApplyEvent, func(offset int64, line []byte, err error) error {
+		skipped = append(skipped, offset)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("applyJournal: %v", err)
+	}
+	second := int64(len(journal[0]) + 1)
+	third := second + int64(len(journal[1])+1)
+	if applied != 2 || len(skipped) != 2 || skipped[0] != second || skipped[1] != third {
+		t.Fatalf("unexpected apply result: applied=%d skipped=%v", applied, skipped)
+	}
+}
+
 func emptyStorage() *gimedic.UserDictionaryStorage {
 	return &gimedic.UserDictionaryStorage{}
 }
@@ -78,3 +147,50 @@ func emptyStorage() *gimedic.UserDictionaryStorage {
 func joinLines(lines []string) string {
 	return strings.Join(lines, "\n") + "\n"
 }
+
+func TestApplyEventHomographs(t *testing.T) {
+	storage := emptyStorage()
+	ApplyEvent(storage, JournalEvent{Op: "add", Dict: "main", Key: "はし", Value: "橋", Pos: 1})
+	ApplyEvent(storage, JournalEvent{Op: "add", Dict: "main", Key: "はし", Value: "橋", Pos: 20})
+	if got := len(storage.GetDictionaries()[0].GetEntries()); got != 2 {
+		t.Fatalf("homographs collapsed: %d entries", got)
+	}
+
+	ApplyEvent(storage, JournalEvent{Op: "update", Dict: "main", Key: "はし", Value: "橋", Pos: 20, Comment: "c"})
+	ApplyEvent(storage, JournalEvent{Op: "delete", Dict: "main", Key: "はし", Value: "橋", Pos: 1})
+	entries := storage.GetDictionaries()[0].GetEntries()
+	if len(entries) != 1 || entries[0].GetPos() != 20 || entries[0].GetComment() != "c" {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+
+	// The POS change moves the entry onto an existing one.
+	ApplyEvent(storage, JournalEvent{Op: "add", Dict: "main", Key: "はし", Value: "橋", Pos: 1})
+	ApplyEvent(storage, JournalEvent{Op: "update", Dict: "main", Key: "はし", Value: "橋", Pos: 1, Comment: "moved",
+		Prev: &EntryState{Key: "はし", Value: "橋", Pos: 20, Comment: "c"}})
+	entries = storage.GetDictionaries()[0].GetEntries()
+	if len(entries) != 1 || entries[0].GetPos() != 1 || entries[0].GetComment() != "moved" {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+}
+
+func TestApplyEventLegacyPosUpdate(t *testing.T) {
+	storage := emptyStorage()
+	ApplyEvent(storage, JournalEvent{Op: "add", Dict: "main", Key: "k", Value: "v", Pos: 1})
+	if !ApplyEvent(storage, JournalEvent{Op: "update", Dict: "main", Key: "k", Value: "v", Pos: 2}) {
+		t.Fatal("update without prev not applied")
+	}
+	entries := storage.GetDictionaries()[0].GetEntries()
+	if len(entries) != 1 || entries[0].GetPos() != 2 {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+}
+
+func TestApplyEventUnnamedDictionary(t *testing.T) {
+	id := uint64(1)
+	storage := &gimedic.UserDictionaryStorage{Dictionaries: []*gimedic.UserDictionary{{Id: &id}}}
+	ApplyEvent(storage, JournalEvent{Op: "add", Dict: "default", Key: "k", Value: "v", Pos: 1})
+	dicts := storage.GetDictionaries()
+	if len(dicts) != 1 || len(dicts[0].GetEntries()) != 1 {
+		t.Fatalf("event not applied to the unnamed dictionary: %v", dicts)
+	}
+}
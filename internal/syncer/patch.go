@@ -0,0 +1,116 @@
+package syncer
+
+import (
+	"fmt"
+
+	"github.com/kyoh86/gimedic"
+)
+
+// PatchResult tells how an event relates to the storage it is applied to.
+type PatchResult int
+
+const (
+	// PatchClean means the event applies as recorded.
+	PatchClean PatchResult = iota
+	// PatchApplied means the change of the event is already in the storage.
+	PatchApplied
+	// PatchConflict means the storage differs from what the event expects.
+	PatchConflict
+)
+
+// CheckEvent reports whether the event applies cleanly to the storage,
+// with the reason when it does not.
+func CheckEvent(storage *gimedic.UserDictionaryStorage, event JournalEvent) (PatchResult, string) {
+	want := EntryState{Key: event.Key, Value: event.Value, Comment: event.Comment, Locale: event.Locale, Pos: event.Pos}
+	current := findEntryState(storage, event.Dict, want.ID())
+	switch event.Op {
+	case "add":
+		switch {
+		case current == nil:
+			return PatchClean, ""
+		case entryStateEqual(*current, want):
+			return PatchApplied, "entry already exists"
+		}
+		return PatchConflict, "entry already exists with other fields"
+	case "update":
+		target := findEntryState(storage, event.Dict, event.targetID())
+		if target == nil && event.Prev == nil {
+			// As in ApplyEvent, an update without Prev may change the
+			// POS of the only entry with its key and value.
+			target = soleEntryState(storage, event.Dict, event.Key, event.Value)
+		}
+		if target != nil || event.Prev == nil {
+			current = target
+		} else if current != nil && entryStateEqual(*current, want) {
+			return PatchApplied, "entry is already up to date"
+		}
+		switch {
+		case current == nil:
+			return PatchConflict, "entry is missing"
+		case entryStateEqual(*current, want):
+			return PatchApplied, "entry is already up to date"
+		case event.Prev != nil && !entryStateEqual(*current, *event.Prev):
+			return PatchConflict, "entry has changed since the event was recorded"
+		}
+		return PatchClean, ""
+	case "delete":
+		switch {
+		case current == nil:
+			return PatchApplied, "entry is already deleted"
+		case !entryStateEqual(*current, want):
+			return PatchConflict, "entry has changed since the event was recorded"
+		}
+		return PatchClean, ""
+	}
+	return PatchConflict, fmt.Sprintf("unknown op %q", event.Op)
+}
+
+// soleEntryState returns the state of the only entry with the key and
+// value, if there is exactly one.
+func soleEntryState(storage *gimedic.UserDictionaryStorage, dictName, key, value string) *EntryState {
+	dict := findDictionary(storage, dictName)
+	if dict == nil {
+		return nil
+	}
+	if entry := soleEntry(dict, key, value); entry != nil {
+		state := entryStateFromProto(entry)
+		return &state
+	}
+	return nil
+}
+
+// ReverseEvent returns the event that undoes the event. Updates can only
+// be reversed when they record the entry they replaced.
+func ReverseEvent(event JournalEvent) (JournalEvent, error) {
+	reversed := event
+	reversed.Timestamp, reversed.Clock, reversed.Origin = "", HLC{}, ""
+	switch event.Op {
+	case "add":
+		reversed.Op = "delete"
+	case "delete":
+		reversed.Op = "add"
+	case "update":
+		if event.Prev == nil {
+			return JournalEvent{}, fmt.Errorf("update of %s/%s does not record the previous entry", event.Key, event.Value)
+		}
+		reversed.Comment = event.Prev.Comment
+		reversed.Locale = event.Prev.Locale
+		reversed.Pos = event.Prev.Pos
+		reversed.Prev = &EntryState{Key: event.Key, Value: event.Value, Comment: event.Comment, Locale: event.Locale, Pos: event.Pos}
+	default:
+		return JournalEvent{}, fmt.Errorf("unknown op %q", event.Op)
+	}
+	return reversed, nil
+}
+
+func findEntryState(storage *gimedic.UserDictionaryStorage, dictName, id string) *EntryState {
+	dict := findDictionary(storage, dictName)
+	if dict == nil {
+		return nil
+	}
+	if entry := findEntry(dict, id); entry != nil {
+		state := entryStateFromProto(entry)
+		return &state
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package syncer
+
+import (
+	"testing"
+
+	"github.com/kyoh86/gimedic"
+)
+
+func TestCheckEvent(t *testing.T) {
+	storage := &gimedic.UserDictionaryStorage{}
+	ApplyEvent(storage, JournalEvent{Op: "add", Dict: "A", Key: "k1", Value: "v1", Comment: "now", Pos: 1})
+
+	tests := []struct {
+		name  string
+		event JournalEvent
+		want  PatchResult
+	}{
+		{"add new", JournalEvent{Op: "add", Dict: "A", Key: "k2", Value: "v2", Pos: 1}, PatchClean},
+		{"add existing", JournalEvent{Op: "add", Dict: "A", Key: "k1", Value: "v1", Comment: "now", Pos: 1}, PatchApplied},
+		{"add different", JournalEvent{Op: "add", Dict: "A", Key: "k1", Value: "v1", Pos: 1}, PatchConflict},
+		{"update", JournalEvent{Op: "update", Dict: "A", Key: "k1", Value: "v1", Comment: "next", Pos: 1,
+			Prev: &EntryState{Key: "k1", Value: "v1", Comment: "now", Pos: 1}}, PatchClean},
+		{"update stale", JournalEvent{Op: "update", Dict: "A", Key: "k1", Value: "v1", Comment: "next", Pos: 1,
+			Prev: &EntryState{Key: "k1", Value: "v1", Comment: "before", Pos: 1}}, PatchConflict},
+		{"update missing", JournalEvent{Op: "update", Dict: "B", Key: "k1", Value: "v1", Pos: 1}, PatchConflict},
+		{"update legacy POS change", JournalEvent{Op: "update", Dict: "A", Key: "k1", Value: "v1", Comment: "now", Pos: 2}, PatchClean},
+		{"delete", JournalEvent{Op: "delete", Dict: "A", Key: "k1", Value: "v1", Comment: "now", Pos: 1}, PatchClean},
+		{"delete changed", JournalEvent{Op: "delete", Dict: "A", Key: "k1", Value: "v1", Pos: 1}, PatchConflict},
+		{"delete missing", JournalEvent{Op: "delete", Dict: "A", Key: "k9", Value: "v9", Pos: 1}, PatchApplied},
+	}
+	for _, tt := range tests {
+		if got, reason := CheckEvent(storage, tt.event); got != tt.want {
+			t.Errorf("%s: got %v (%s), want %v", tt.name, got, reason, tt.want)
+		}
+	}
+
+	// Once applied, the legacy update is reported as applied.
+	legacy := JournalEvent{Op: "update", Dict: "A", Key: "k1", Value: "v1", Comment: "now", Pos: 2}
+	if !ApplyEvent(storage, legacy) {
+		t.Fatal("legacy update not applied")
+	}
+	if got, reason := CheckEvent(storage, legacy); got != PatchApplied {
+		t.Errorf("applied legacy update: got %v (%s), want %v", got, reason, PatchApplied)
+	}
+}
+
+func TestReverseEvent(t *testing.T) {
+	before := snapshotOf("A", EntryState{Key: "k", Value: "v", Comment: "old", Pos: 1}, EntryState{Key: "d", Value: "v", Pos: 2})
+	after := snapshotOf("A", EntryState{Key: "k", Value: "v", Comment: "new", Pos: 3}, EntryState{Key: "a", Value: "v", Pos: 1})
+	storage := &gimedic.UserDictionaryStorage{}
+	for _, event := range DiffSnapshots(Snapshot{}, after) {
+		ApplyEvent(storage, event)
+	}
+	events := DiffSnapshots(before, after)
+	for i := len(events) - 1; i >= 0; i-- {
+		reversed, err := ReverseEvent(events[i])
+		if err != nil {
+			t.Fatalf("ReverseEvent: %v", err)
+		}
+		if result, reason := CheckEvent(storage, reversed); result != PatchClean {
+			t.Fatalf("reversed %s does not apply cleanly: %s", reversed.Op, reason)
+		}
+		ApplyEvent(storage, reversed)
+	}
+	if got := DiffSnapshots(before, SnapshotFromStorage(storage)); len(got) != 0 {
+		t.Fatalf("reverse left differences: %#v", got)
+	}
+
+	if _, err := ReverseEvent(JournalEvent{Op: "update", Key: "k", Value: "v"}); err == nil {
+		t.Fatal("expected error for update without previous entry")
+	}
+}
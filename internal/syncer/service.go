@@ -1,11 +1,37 @@
 package syncer
 
-import "time"
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kyoh86/gimedic"
+)
 
 type Service struct {
 	DBPath          string
 	JournalDir      string
 	InhibitDuration time.Duration
+	// Origin is the peer id stamped on pushed events. It defaults to
+	// JournalIdentity.
+	Origin string
+	// Warn, when set, is told about journal trouble Pull works around,
+	// such as malformed records it quarantines.
+	Warn func(msg string)
+}
+
+func (s Service) warnf(format string, args ...any) {
+	if s.Warn != nil {
+		s.Warn(fmt.Sprintf(format, args...))
+	}
+}
+
+func (s Service) origin() string {
+	if s.Origin != "" {
+		return s.Origin
+	}
+	return JournalIdentity()
 }
 
 func (s Service) ResolveJournalPath(arg string) (string, error) {
@@ -46,9 +72,24 @@ func (s Service) Push(journalPath string) (int, error) {
 	current := SnapshotFromStorage(storage)
 	localEvents := DiffSnapshots(state.Snapshot, current)
 	if len(localEvents) > 0 {
+		clockPath, err := dbStatePath(s.DBPath)
+		if err != nil {
+			return 0, err
+		}
+		clocks, err := loadDBState(clockPath)
+		if err != nil {
+			return 0, err
+		}
+		origin := s.origin()
+		for i := range localEvents {
+			stampEvent(&clocks, &localEvents[i], origin)
+		}
 		if err := AppendJournalEvents(journalPath, localEvents); err != nil {
 			return 0, err
 		}
+		if err := saveDBState(clockPath, clocks); err != nil {
+			return 0, err
+		}
 	}
 
 	state.Snapshot = current
@@ -58,12 +99,39 @@ func (s Service) Push(journalPath string) (int, error) {
 	return len(localEvents), nil
 }
 
+// Pull applies the events of the journals that are new since the last
+// pull. Events are resolved by the last-writer-wins rule of
+// applyStampedEvent, so peers that pulled the same events hold the same
+// entries whatever order they pulled them in. An event that would
+// overwrite an entry changed locally since the last push or pull is not
+// applied but recorded as a Conflict; local changes stay in place to be
+// pushed.
 func (s Service) Pull(journalPaths []string) (int, error) {
 	appliedTotal := 0
 	selfJournalPath, err := s.OwnJournalPath()
 	if err != nil {
 		return 0, err
 	}
+	clockPath, err := dbStatePath(s.DBPath)
+	if err != nil {
+		return 0, err
+	}
+	// The own snapshot is the dictionary as of the last sync. Without one
+	// nothing tells local changes apart, and conflicts are not detected.
+	selfStatePath, err := SyncStatePath(s.DBPath, selfJournalPath)
+	if err != nil {
+		return 0, err
+	}
+	_, err = os.Stat(selfStatePath)
+	synced := err == nil
+	selfState, err := LoadSyncState(selfStatePath)
+	if err != nil {
+		return 0, err
+	}
+	base := selfState.Snapshot
+	if base.Dictionaries == nil {
+		base.Dictionaries = map[string]map[string]EntryState{}
+	}
 	for _, journalPath := range journalPaths {
 		statePath, err := SyncStatePath(s.DBPath, journalPath)
 		if err != nil {
@@ -73,11 +141,76 @@ func (s Service) Pull(journalPaths []string) (int, error) {
 		if err != nil {
 			return 0, err
 		}
+		// A journal that shrank or starts differently was truncated,
+		// replaced or compacted: the offset into the old content means
+		// nothing, so the journal is read again from the start. Events
+		// pulled before carry the stamps recorded for their entries, which
+		// makes dbState.wins pass over them.
+		fingerprint, size, err := journalFingerprint(journalPath)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case size < state.JournalOffset:
+			s.warnf("%s: journal shrank below the pulled offset; reading it again", journalPath)
+			state.JournalOffset = 0
+		case fingerprint == state.JournalID:
+		case state.JournalID == "" && strings.HasPrefix(fingerprint, headFingerprintPrefix):
+			// Pulled before fingerprints were recorded; take it as is.
+		case state.JournalOffset > 0:
+			s.warnf("%s: journal was replaced; reading it again", journalPath)
+			state.JournalOffset = 0
+		}
+		state.JournalID = fingerprint
 
-		applied, changed, newOffset, err := ApplyJournal(s.DBPath, journalPath, state.JournalOffset)
+		clocks, err := loadDBState(clockPath)
 		if err != nil {
 			return 0, err
 		}
+		var conflicts []Conflict
+		applied, changed, newOffset, err := applyJournal(s.DBPath, journalPath, state.JournalOffset, func(storage *gimedic.UserDictionaryStorage, event JournalEvent) bool {
+			if synced {
+				if conflict := detectConflict(storage, base, &clocks, journalPath, event); conflict != nil {
+					// Seeing the event keeps the resolution stamped after it.
+					clocks.observe(event)
+					conflicts = append(conflicts, *conflict)
+					return false
+				}
+			}
+			// Entries the event loses on keep their state, which may be
+			// a local change yet to push.
+			var won []string
+			for _, id := range eventEntryIDs(event) {
+				if clocks.wins(event.Dict, id, event.stamp()) {
+					won = append(won, id)
+				}
+			}
+			changed := applyStampedEvent(storage, &clocks, event)
+			updateBase(storage, base, event.Dict, won)
+			return changed
+		}, func(offset int64, line []byte, lineErr error) error {
+			quarantined, added, err := quarantineRecord(s.DBPath, QuarantinedRecord{
+				Journal:     journalPath,
+				Fingerprint: fingerprint,
+				Offset:      offset,
+				Error:       lineErr.Error(),
+				Line:        string(line),
+			})
+			if err != nil || !added {
+				return err
+			}
+			s.warnf("%s: skipped malformed record at offset %d (%v); kept in %s", journalPath, offset, lineErr, quarantined)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		if err := saveDBState(clockPath, clocks); err != nil {
+			return 0, err
+		}
+		if err := addConflicts(s.DBPath, conflicts); err != nil {
+			return 0, err
+		}
 		appliedTotal += applied
 
 		if changed {
@@ -96,7 +229,14 @@ func (s Service) Pull(journalPaths []string) (int, error) {
 			return 0, err
 		}
 	}
-	if err := RefreshOwnSnapshot(s.DBPath, selfJournalPath); err != nil {
+	if !synced {
+		if err := RefreshOwnSnapshot(s.DBPath, selfJournalPath); err != nil {
+			return appliedTotal, err
+		}
+		return appliedTotal, nil
+	}
+	selfState.Snapshot = base
+	if err := SaveSyncState(selfStatePath, selfState); err != nil {
 		return appliedTotal, err
 	}
 	return appliedTotal, nil
@@ -1,8 +1,10 @@
 package syncer
 
 import (
+	"encoding/json"
 	"os"
 	"os/user"
+	"strings"
 	"testing"
 	"time"
 
@@ -93,3 +95,148 @@ func storageWithEntry(dictName, key, value string) *gimedic.UserDictionaryStorag
 		Dictionaries: []*gimedic.UserDictionary{dict},
 	}
 }
+
+func TestServicePushStampsEvents(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	dbPath := dir + "/user_dictionary.db"
+	journalPath := dir + "/self.jsonl"
+	if err := WriteStorage(dbPath, storageWithEntry("main", "k1", "v1")); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	service := Service{DBPath: dbPath, JournalDir: dir, Origin: "peer-a"}
+	if _, err := service.Push(journalPath); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	data, err := os.ReadFile(journalPath)
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	var event JournalEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("unmarshal event: %v", err)
+	}
+	if event.Clock == (HLC{}) || event.Origin != "peer-a" {
+		t.Fatalf("event not stamped: %#v", event)
+	}
+
+	// A remote write older than the local one is not applied.
+	other := dir + "/other.jsonl"
+	line := `{"op":"delete","dict":"main","key":"k1","value":"v1","pos":1,"hlc":{"wall":1,"logical":0},"origin":"peer-b"}`
+	if err := os.WriteFile(other, []byte(line+"\n"), 0o644); err != nil {
+		t.Fatalf("write other journal: %v", err)
+	}
+	if applied, err := service.Pull([]string{other}); err != nil || applied != 0 {
+		t.Fatalf("Pull: applied=%d err=%v", applied, err)
+	}
+}
+
+func TestServicePullDamagedJournal(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	dbPath := dir + "/user_dictionary.db"
+	journalDir := dir + "/journals"
+	if err := os.MkdirAll(journalDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := WriteStorage(dbPath, emptyStorage()); err != nil {
+		t.Fatalf("WriteStorage: %v", err)
+	}
+	var warnings []string
+	service := Service{
+		DBPath:     dbPath,
+		JournalDir: journalDir,
+		Origin:     "Host-User",
+		Warn:       func(msg string) { warnings = append(warnings, msg) },
+	}
+
+	otherJournal := journalDir + "/Other.jsonl"
+	write := func(lines ...string) {
+		t.Helper()
+		if err := os.WriteFile(otherJournal, []byte(joinLines(lines)), 0o644); err != nil {
+			t.Fatalf("write other journal: %v", err)
+		}
+	}
+	entries := func() []string {
+		t.Helper()
+		storage, err := LoadStorage(dbPath)
+		if err != nil {
+			t.Fatalf("LoadStorage: %v", err)
+		}
+		var keys []string
+		for _, dict := range storage.GetDictionaries() {
+			for _, entry := range dict.GetEntries() {
+				keys = append(keys, entry.GetKey())
+			}
+		}
+		return keys
+	}
+
+	write(
+		`{"op":"add","dict":"main","key":"k1","value":"v1","pos":1}`,
+		`not json`,
+		`{"op":"add","dict":"main","key":"k2","value":"v2","pos":1}`,
+		`{"op":"add","dict":"main","key":"k3","value":"v3","pos":1}`,
+	)
+	if _, err := service.Pull([]string{otherJournal}); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if got := entries(); len(got) != 3 {
+		t.Fatalf("expected 3 entries, got %v", got)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected a warning for the malformed record, got %v", warnings)
+	}
+	quarantine, err := QuarantinePath(dbPath)
+	if err != nil {
+		t.Fatalf("QuarantinePath: %v", err)
+	}
+	if raw, err := os.ReadFile(quarantine); err != nil || !strings.Contains(string(raw), "not json") {
+		t.Fatalf("expected the record quarantined: %q, %v", raw, err)
+	}
+
+	// Re-reading the same content does not quarantine the record again.
+	statePath, err := SyncStatePath(dbPath, otherJournal)
+	if err != nil {
+		t.Fatalf("SyncStatePath: %v", err)
+	}
+	state, err := LoadSyncState(statePath)
+	if err != nil {
+		t.Fatalf("LoadSyncState: %v", err)
+	}
+	state.JournalOffset = 0
+	if err := SaveSyncState(statePath, state); err != nil {
+		t.Fatalf("SaveSyncState: %v", err)
+	}
+	if _, err := service.Pull([]string{otherJournal}); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if raw, _ := os.ReadFile(quarantine); len(warnings) != 1 || strings.Count(string(raw), "\n") != 1 {
+		t.Fatalf("record quarantined again: %v\n%s", warnings, raw)
+	}
+
+	// Replaced by a shorter journal: read again from the start.
+	write(`{"op":"add","dict":"main","key":"k4","value":"v4","pos":1}`)
+	if _, err := service.Pull([]string{otherJournal}); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if got := entries(); len(got) != 4 {
+		t.Fatalf("expected 4 entries after truncation, got %v", got)
+	}
+
+	// Replaced by a longer journal with different content.
+	write(
+		`{"op":"add","dict":"main","key":"k5","value":"v5","pos":1}`,
+		`{"op":"add","dict":"main","key":"k6","value":"v6","pos":1}`,
+	)
+	if _, err := service.Pull([]string{otherJournal}); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if got := entries(); len(got) != 6 {
+		t.Fatalf("expected 6 entries after replacement, got %v", got)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("expected warnings for the rescans, got %v", warnings)
+	}
+}
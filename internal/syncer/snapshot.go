@@ -2,6 +2,11 @@ package syncer
 
 import "github.com/kyoh86/gimedic"
 
+// ID returns the gimedic.EntryID of the entry.
+func (s EntryState) ID() string {
+	return gimedic.EntryID(s.Key, s.Value, s.Pos)
+}
+
 func SnapshotFromStorage(storage *gimedic.UserDictionaryStorage) Snapshot {
 	result := Snapshot{Dictionaries: map[string]map[string]EntryState{}}
 	for _, dict := range storage.GetDictionaries() {
@@ -12,7 +17,7 @@ func SnapshotFromStorage(storage *gimedic.UserDictionaryStorage) Snapshot {
 		entries := map[string]EntryState{}
 		for _, entry := range dict.GetEntries() {
 			state := entryStateFromProto(entry)
-			entries[state.Key+"\u0000"+state.Value] = state
+			entries[state.ID()] = state
 		}
 		result.Dictionaries[name] = entries
 	}
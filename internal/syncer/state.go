@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+
+	"github.com/kyoh86/gimedic"
 )
 
 type JournalEvent struct {
@@ -19,6 +21,24 @@ type JournalEvent struct {
 	Pos       int32  `json:"pos"`
 	Comment   string `json:"comment,omitempty"`
 	Locale    string `json:"locale,omitempty"`
+	// Prev is the entry an update replaced, when known.
+	Prev *EntryState `json:"prev,omitempty"`
+	// Clock and Origin stamp the event with the clock reading and the
+	// peer id of the peer recording it.
+	Clock  HLC    `json:"hlc,omitzero"`
+	Origin string `json:"origin,omitempty"`
+}
+
+func (e JournalEvent) stamp() Stamp {
+	return Stamp{Clock: e.Clock, Origin: e.Origin}
+}
+
+// targetID returns the identity of the entry an update changes.
+func (e JournalEvent) targetID() string {
+	if e.Prev != nil {
+		return e.Prev.ID()
+	}
+	return gimedic.EntryID(e.Key, e.Value, e.Pos)
 }
 
 type EntryState struct {
@@ -33,20 +53,34 @@ type Snapshot struct {
 	Dictionaries map[string]map[string]EntryState `json:"dictionaries"`
 }
 
+// syncStateVersion is the version of the sync state file. Version 2 keys
+// snapshot entries by gimedic.EntryID; earlier files key them by key and
+// value.
+const syncStateVersion = 2
+
 type SyncState struct {
+	Version int `json:"version"`
+	// JournalID is the fingerprint of the journal JournalOffset points
+	// into: the id in its header, or a hash of its first record.
+	JournalID     string   `json:"journal_id,omitempty"`
 	JournalOffset int64    `json:"journal_offset"`
 	Snapshot      Snapshot `json:"snapshot"`
 }
 
 type dbState struct {
 	InhibitUntil string `json:"inhibit_until,omitempty"`
+	// Clock is the last clock reading this peer issued or saw.
+	Clock HLC `json:"hlc,omitzero"`
+	// Stamps holds the stamp of the last write applied to each entry,
+	// keyed by dictionary name and gimedic.EntryID.
+	Stamps map[string]Stamp `json:"stamps,omitempty"`
 }
 
 func LoadSyncState(path string) (SyncState, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return SyncState{Snapshot: Snapshot{Dictionaries: map[string]map[string]EntryState{}}}, nil
+			return SyncState{Version: syncStateVersion, Snapshot: Snapshot{Dictionaries: map[string]map[string]EntryState{}}}, nil
 		}
 		return SyncState{}, err
 	}
@@ -54,10 +88,28 @@ func LoadSyncState(path string) (SyncState, error) {
 	if err := json.Unmarshal(data, &state); err != nil {
 		return SyncState{}, err
 	}
+	migrateSyncState(&state)
 	return state, nil
 }
 
+// migrateSyncState brings a state loaded from an older file up to
+// syncStateVersion. Entries that collapsed under the old keys stay lost
+// from the snapshot; the next push records them as additions.
+func migrateSyncState(state *SyncState) {
+	if state.Version < 2 {
+		for name, entries := range state.Snapshot.Dictionaries {
+			rekeyed := make(map[string]EntryState, len(entries))
+			for _, entry := range entries {
+				rekeyed[entry.ID()] = entry
+			}
+			state.Snapshot.Dictionaries[name] = rekeyed
+		}
+	}
+	state.Version = syncStateVersion
+}
+
 func SaveSyncState(path string, state SyncState) error {
+	state.Version = syncStateVersion
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
@@ -90,7 +142,7 @@ func loadDBState(path string) (dbState, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return dbState{}, nil
+			return dbState{Stamps: map[string]Stamp{}}, nil
 		}
 		return dbState{}, err
 	}
@@ -98,6 +150,9 @@ func loadDBState(path string) (dbState, error) {
 	if err := json.Unmarshal(data, &state); err != nil {
 		return dbState{}, err
 	}
+	if state.Stamps == nil {
+		state.Stamps = map[string]Stamp{}
+	}
 	return state, nil
 }
 
@@ -48,3 +48,21 @@ func TestRefreshOwnSnapshotCreatesState(t *testing.T) {
 		t.Fatalf("state file missing: %v", err)
 	}
 }
+
+func TestLoadSyncStateMigratesSnapshotKeys(t *testing.T) {
+	path := t.TempDir() + "/state.json"
+	legacy := `{"journal_offset":12,"snapshot":{"dictionaries":{"A":{"k\u0000v":{"key":"k","value":"v","pos":3}}}}}`
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	state, err := LoadSyncState(path)
+	if err != nil {
+		t.Fatalf("LoadSyncState: %v", err)
+	}
+	if state.Version != syncStateVersion || state.JournalOffset != 12 {
+		t.Fatalf("unexpected state: %#v", state)
+	}
+	if _, ok := state.Snapshot.Dictionaries["A"][gimedic.EntryID("k", "v", 3)]; !ok {
+		t.Fatalf("snapshot not rekeyed: %#v", state.Snapshot)
+	}
+}
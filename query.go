@@ -0,0 +1,230 @@
+package gimedic
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query selects entries by their fields. See ParseQuery for the syntax.
+type Query struct {
+	terms []queryTerm
+}
+
+// queryFields are the fields a query term can name.
+var queryFields = []string{"key", "value", "pos", "comment", "locale", "dict"}
+
+type matchKind int
+
+const (
+	matchExact matchKind = iota
+	matchPrefix
+	matchRegexp
+	matchContains
+)
+
+type queryTerm struct {
+	field  string // empty matches key, value or comment
+	kind   matchKind
+	text   string
+	re     *regexp.Regexp
+	negate bool
+}
+
+// ParseQuery parses a query made of whitespace-separated terms, all of
+// which an entry must match:
+//
+//	field:text      the field is text
+//	field:text*     the field starts with text
+//	field:/regexp/  the field matches the regular expression
+//	text            the key, value or comment contains text
+//
+// The fields are key, value, pos, comment, locale and dict. pos matches
+// the Part label (e.g. 人名) and exact pos terms accept its number as
+// well; dict matches the dictionary name and exact dict terms accept its
+// id as well. A leading '-' negates a term. Text in double quotes is
+// taken literally and may contain spaces; use \" and \\ inside quotes.
+// The empty query matches every entry.
+func ParseQuery(s string) (*Query, error) {
+	tokens, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	query := &Query{}
+	for _, token := range tokens {
+		term, err := parseQueryTerm(token)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", token.raw, err)
+		}
+		query.terms = append(query.terms, term)
+	}
+	return query, nil
+}
+
+// queryToken is a term as written, split at its first unquoted ':'.
+type queryToken struct {
+	raw      string
+	negate   bool
+	field    string
+	hasField bool
+	text     string
+	quoted   bool
+}
+
+func splitQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	var token queryToken
+	var text strings.Builder
+	inToken, inQuote := false, false
+	flush := func() {
+		if inToken {
+			token.text = text.String()
+			tokens = append(tokens, token)
+		}
+		token, inToken = queryToken{}, false
+		text.Reset()
+	}
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case inQuote && r == '\\' && i+1 < len(runes):
+			i++
+			token.raw += string(r)
+			r = runes[i]
+			text.WriteRune(r)
+		case inQuote && r == '"':
+			inQuote = false
+		case inQuote:
+			text.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+			continue
+		case r == '"':
+			inQuote, token.quoted = true, true
+		case r == '-' && !inToken:
+			token.negate = true
+		case r == ':' && !token.hasField && !token.quoted:
+			token.field, token.hasField = text.String(), true
+			text.Reset()
+		default:
+			text.WriteRune(r)
+		}
+		inToken = true
+		token.raw += string(r)
+	}
+	if inQuote {
+		return nil, errors.New("unterminated quote")
+	}
+	flush()
+	return tokens, nil
+}
+
+func parseQueryTerm(token queryToken) (queryTerm, error) {
+	term := queryTerm{negate: token.negate}
+	if token.hasField {
+		term.field = strings.ToLower(token.field)
+		known := false
+		for _, field := range queryFields {
+			known = known || field == term.field
+		}
+		if !known {
+			return term, fmt.Errorf("unknown field %q (available: %s)", token.field, strings.Join(queryFields, ", "))
+		}
+	}
+	text := token.text
+	switch {
+	case token.quoted:
+		term.kind = matchExact
+	case len(text) >= 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/"):
+		re, err := regexp.Compile(text[1 : len(text)-1])
+		if err != nil {
+			return term, err
+		}
+		term.kind, term.re = matchRegexp, re
+	case strings.HasSuffix(text, "*"):
+		term.kind, text = matchPrefix, strings.TrimSuffix(text, "*")
+	default:
+		term.kind = matchExact
+	}
+	if term.field == "" && term.kind == matchExact {
+		term.kind = matchContains
+	}
+	term.text = text
+	return term, nil
+}
+
+// Match reports whether the record matches every term of the query.
+func (q *Query) Match(r Record) bool {
+	for _, term := range q.terms {
+		if term.match(r) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (t queryTerm) match(r Record) bool {
+	switch t.field {
+	case "":
+		return t.matchText(r.Key) || t.matchText(r.Value) || t.matchText(r.Comment)
+	case "key":
+		return t.matchText(r.Key)
+	case "value":
+		return t.matchText(r.Value)
+	case "comment":
+		return t.matchText(r.Comment)
+	case "locale":
+		return t.matchText(r.Locale)
+	case "pos":
+		if t.kind == matchExact {
+			if n, err := strconv.Atoi(t.text); err == nil {
+				return Part(n) == r.Pos
+			}
+		}
+		return t.matchText(r.Pos.String())
+	case "dict":
+		if t.kind == matchExact && t.text == strconv.FormatUint(r.DictionaryID, 10) {
+			return true
+		}
+		return t.matchText(r.DictionaryName)
+	}
+	return false
+}
+
+func (t queryTerm) matchText(s string) bool {
+	switch t.kind {
+	case matchPrefix:
+		return strings.HasPrefix(s, t.text)
+	case matchRegexp:
+		return t.re.MatchString(s)
+	case matchContains:
+		return strings.Contains(s, t.text)
+	}
+	return s == t.text
+}
+
+// Filter returns a storage holding only the entries that match the
+// query, leaving out dictionaries without any. Dictionaries are new
+// messages but entries are shared with storage.
+func (q *Query) Filter(storage *UserDictionaryStorage) *UserDictionaryStorage {
+	filtered := &UserDictionaryStorage{Version: storage.Version}
+	for _, dict := range storage.GetDictionaries() {
+		var entries []*UserDictionary_Entry
+		for _, entry := range dict.GetEntries() {
+			if q.Match(newRecord(dict, entry)) {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) > 0 {
+			filtered.Dictionaries = append(filtered.Dictionaries, &UserDictionary{
+				Id:      dict.Id,
+				Name:    dict.Name,
+				Entries: entries,
+			})
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,79 @@
+package gimedic
+
+import (
+	"testing"
+)
+
+func TestQueryMatch(t *testing.T) {
+	record := Record{
+		DictionaryID:   7,
+		DictionaryName: "people",
+		Key:            "あべ",
+		Value:          "阿部",
+		Pos:            PartSurname,
+		Comment:        "see JIRA-12 for details",
+		Locale:         "ja",
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"key:あべ", true},
+		{"key:あ", false},
+		{"key:あ*", true},
+		{"key:あ* pos:姓", true},
+		{"key:あ* pos:人名", false},
+		{"pos:6", true},
+		{"pos:/^姓$/", true},
+		{"comment:/JIRA-\\d+/", true},
+		{"JIRA", true},
+		{"jira", false},
+		{"comment:/(?i)jira/", true},
+		{"-comment:/JIRA/", false},
+		{"-locale:en", true},
+		{"dict:people", true},
+		{"dict:7", true},
+		{"dict:peo*", true},
+		{`comment:"see JIRA-12 for details"`, true},
+		{`"for details"`, true},
+		{`value:"阿*"`, false},
+		{"VALUE:阿部", true},
+	}
+	for _, tt := range tests {
+		query, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tt.query, err)
+		}
+		if got := query.Match(record); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, input := range []string{
+		"name:x",
+		"key:/(/",
+		`comment:"open`,
+	} {
+		if _, err := ParseQuery(input); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestQueryFilter(t *testing.T) {
+	query, err := ParseQuery("pos:顔文字")
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	filtered := query.Filter(sampleStorage())
+	if len(filtered.GetDictionaries()) != 1 {
+		t.Fatalf("unexpected dictionaries: %v", filtered.GetDictionaries())
+	}
+	dict := filtered.GetDictionaries()[0]
+	if dict.GetName() != "main" || len(dict.GetEntries()) != 1 || dict.GetEntries()[0].GetKey() != "かお" {
+		t.Fatalf("unexpected dictionary: %v", dict)
+	}
+}
@@ -0,0 +1,231 @@
+package gimedic
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Record is a flat representation of a single entry with its dictionary.
+type Record struct {
+	DictionaryID   uint64
+	DictionaryName string
+	Key            string
+	Value          string
+	Pos            Part
+	Comment        string
+	Locale         string
+}
+
+// RecordColumns are the column names used by the tabular formats.
+var RecordColumns = []string{
+	"dictionary_id",
+	"dictionary",
+	"key",
+	"value",
+	"pos",
+	"pos_name",
+	"comment",
+	"locale",
+}
+
+// Records flattens the storage into records in dictionary order.
+func Records(storage *UserDictionaryStorage) []Record {
+	var records []Record
+	for _, dict := range storage.GetDictionaries() {
+		for _, entry := range dict.GetEntries() {
+			records = append(records, newRecord(dict, entry))
+		}
+	}
+	return records
+}
+
+// flatRecords is Records with a dictionary-only record, one with no key
+// and value, for each dictionary without entries, so that the flat
+// formats keep empty dictionaries too.
+func flatRecords(storage *UserDictionaryStorage) []Record {
+	var records []Record
+	for _, dict := range storage.GetDictionaries() {
+		if len(dict.GetEntries()) == 0 {
+			records = append(records, Record{DictionaryID: dict.GetId(), DictionaryName: dict.GetName()})
+		}
+		for _, entry := range dict.GetEntries() {
+			records = append(records, newRecord(dict, entry))
+		}
+	}
+	return records
+}
+
+// dictionaryOnly reports whether the record stands for a dictionary
+// without entries.
+func (r Record) dictionaryOnly() bool {
+	return r.Key == "" && r.Value == ""
+}
+
+func newRecord(dict *UserDictionary, entry *UserDictionary_Entry) Record {
+	return Record{
+		DictionaryID:   dict.GetId(),
+		DictionaryName: dict.GetName(),
+		Key:            entry.GetKey(),
+		Value:          entry.GetValue(),
+		Pos:            Part(entry.GetPos()),
+		Comment:        entry.GetComment(),
+		Locale:         entry.GetLocale(),
+	}
+}
+
+func (r Record) fields() []string {
+	if r.dictionaryOnly() {
+		return []string{strconv.FormatUint(r.DictionaryID, 10), r.DictionaryName, "", "", "", "", "", ""}
+	}
+	return []string{
+		strconv.FormatUint(r.DictionaryID, 10),
+		r.DictionaryName,
+		r.Key,
+		r.Value,
+		strconv.Itoa(int(r.Pos)),
+		r.Pos.String(),
+		r.Comment,
+		r.Locale,
+	}
+}
+
+// StorageFromRecords groups records into dictionaries by name, keeping the
+// order in which dictionaries and entries first appear.
+// Dictionaries whose records carry no id are left with id 0.
+// A record with no key and value adds its dictionary but no entry.
+func StorageFromRecords(records []Record) (*UserDictionaryStorage, error) {
+	storage := &UserDictionaryStorage{}
+	dicts := map[string]*UserDictionary{}
+	for _, record := range records {
+		dict := dicts[record.DictionaryName]
+		if dict == nil {
+			name := record.DictionaryName
+			dict = &UserDictionary{Name: &name, Entries: []*UserDictionary_Entry{}}
+			dicts[name] = dict
+			storage.Dictionaries = append(storage.Dictionaries, dict)
+		}
+		if record.DictionaryID != 0 {
+			if dict.GetId() != 0 && dict.GetId() != record.DictionaryID {
+				return nil, fmt.Errorf("dictionary %q has conflicting ids %d and %d", record.DictionaryName, dict.GetId(), record.DictionaryID)
+			}
+			id := record.DictionaryID
+			dict.Id = &id
+		}
+		if record.dictionaryOnly() {
+			continue
+		}
+		dict.Entries = append(dict.Entries, record.Entry())
+	}
+	return storage, nil
+}
+
+// Entry builds a dictionary entry from the record.
+func (r Record) Entry() *UserDictionary_Entry {
+	key := r.Key
+	value := r.Value
+	pos := UserDictionary_PosType(r.Pos)
+	comment := r.Comment
+	locale := r.Locale
+	return &UserDictionary_Entry{
+		Key:     &key,
+		Value:   &value,
+		Pos:     &pos,
+		Comment: &comment,
+		Locale:  &locale,
+	}
+}
+
+// resolvePart resolves the POS from a numeric or labeled pos field and an
+// optional pos_name label. Both empty means PartNoun, the Mozc default.
+func resolvePart(pos, posName string) (Part, error) {
+	var part Part
+	var hasPart bool
+	if pos != "" {
+		if n, err := strconv.Atoi(pos); err == nil {
+			if n < 0 || n >= len(partNames) {
+				return PartNone, fmt.Errorf("pos %d out of range", n)
+			}
+			part, hasPart = Part(n), true
+		} else if p, ok := ParsePart(pos); ok {
+			part, hasPart = p, true
+		} else {
+			return PartNone, fmt.Errorf("unknown pos %q", pos)
+		}
+	}
+	if posName != "" {
+		p, ok := ParsePart(posName)
+		if !ok {
+			return PartNone, fmt.Errorf("unknown pos_name %q", posName)
+		}
+		if hasPart && p != part {
+			return PartNone, fmt.Errorf("pos %q and pos_name %q disagree", pos, posName)
+		}
+		part, hasPart = p, true
+	}
+	if !hasPart {
+		return PartNoun, nil
+	}
+	return part, nil
+}
+
+// recordFromColumns builds a record from a row of the tabular formats.
+// The header maps column names to indexes; key and value are required.
+func recordFromColumns(header map[string]int, row []string) (Record, error) {
+	get := func(name string) string {
+		if i, ok := header[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	var record Record
+	if id := get("dictionary_id"); id != "" {
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return Record{}, fmt.Errorf("invalid dictionary_id %q", id)
+		}
+		record.DictionaryID = n
+	}
+	record.DictionaryName = get("dictionary")
+	record.Key = get("key")
+	record.Value = get("value")
+	if record.dictionaryOnly() && (record.DictionaryID != 0 || record.DictionaryName != "") {
+		return record, nil
+	}
+	if record.Key == "" {
+		return Record{}, errors.New("empty key")
+	}
+	if record.Value == "" {
+		return Record{}, errors.New("empty value")
+	}
+	part, err := resolvePart(get("pos"), get("pos_name"))
+	if err != nil {
+		return Record{}, err
+	}
+	record.Pos = part
+	record.Comment = get("comment")
+	record.Locale = get("locale")
+	return record, nil
+}
+
+// columnIndex maps header names to their indexes and checks required columns.
+func columnIndex(header []string) (map[string]int, error) {
+	index := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.TrimSpace(name)
+		if _, dup := index[name]; dup {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		index[name] = i
+	}
+	for _, name := range []string{"key", "value"} {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	return index, nil
+}
@@ -0,0 +1,135 @@
+package gimedic
+
+import (
+	"regexp"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// EntryID identifies an entry within its dictionary. Mozc keeps entries
+// that share key and value but differ in POS apart, so the POS is part of
+// the identity.
+func EntryID(key, value string, pos int32) string {
+	return key + "\u0000" + value + "\u0000" + strconv.Itoa(int(pos))
+}
+
+// The set operations identify entries by EntryID, like the syncer does,
+// so homographs differing only in POS are kept apart. They return copies
+// of the entries, ready to be put into a new dictionary.
+
+func entryIdentity(entry *UserDictionary_Entry) string {
+	return EntryID(entry.GetKey(), entry.GetValue(), int32(entry.GetPos()))
+}
+
+func entryIdentities(dict *UserDictionary) map[string]struct{} {
+	ids := map[string]struct{}{}
+	for _, entry := range dict.GetEntries() {
+		ids[entryIdentity(entry)] = struct{}{}
+	}
+	return ids
+}
+
+func cloneEntry(entry *UserDictionary_Entry) *UserDictionary_Entry {
+	return proto.Clone(entry).(*UserDictionary_Entry)
+}
+
+// Union returns the entries found in any of the dictionaries, each once.
+// The first dictionary holding an entry decides its comment and locale.
+func Union(dicts ...*UserDictionary) []*UserDictionary_Entry {
+	seen := map[string]struct{}{}
+	entries := []*UserDictionary_Entry{}
+	for _, dict := range dicts {
+		for _, entry := range dict.GetEntries() {
+			id := entryIdentity(entry)
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			entries = append(entries, cloneEntry(entry))
+		}
+	}
+	return entries
+}
+
+// Intersection returns the entries of the first dictionary that every
+// other dictionary holds as well.
+func Intersection(first *UserDictionary, others ...*UserDictionary) []*UserDictionary_Entry {
+	sets := make([]map[string]struct{}, 0, len(others))
+	for _, dict := range others {
+		sets = append(sets, entryIdentities(dict))
+	}
+	entries := []*UserDictionary_Entry{}
+	for _, entry := range first.GetEntries() {
+		id := entryIdentity(entry)
+		inAll := true
+		for _, set := range sets {
+			if _, ok := set[id]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			entries = append(entries, cloneEntry(entry))
+		}
+	}
+	return entries
+}
+
+// Subtract returns the entries of from that none of the others holds.
+func Subtract(from *UserDictionary, others ...*UserDictionary) []*UserDictionary_Entry {
+	excluded := map[string]struct{}{}
+	for _, dict := range others {
+		for id := range entryIdentities(dict) {
+			excluded[id] = struct{}{}
+		}
+	}
+	entries := []*UserDictionary_Entry{}
+	for _, entry := range from.GetEntries() {
+		if _, ok := excluded[entryIdentity(entry)]; !ok {
+			entries = append(entries, cloneEntry(entry))
+		}
+	}
+	return entries
+}
+
+// Split partitions the entries of the dictionary by the group each
+// belongs to, keeping their order within a group.
+func Split(dict *UserDictionary, group func(*UserDictionary_Entry) string) map[string][]*UserDictionary_Entry {
+	groups := map[string][]*UserDictionary_Entry{}
+	for _, entry := range dict.GetEntries() {
+		name := group(entry)
+		groups[name] = append(groups[name], cloneEntry(entry))
+	}
+	return groups
+}
+
+// GroupByPart groups entries by their POS label.
+func GroupByPart(entry *UserDictionary_Entry) string {
+	return Part(entry.GetPos()).String()
+}
+
+// UntaggedGroup is the group GroupByTag gives entries without a tag.
+const UntaggedGroup = "untagged"
+
+var commentTag = regexp.MustCompile(`(?:^|\s)#([^\s#]+)`)
+
+// GroupByTag groups entries by the first #tag in their comment.
+func GroupByTag(entry *UserDictionary_Entry) string {
+	if m := commentTag.FindStringSubmatch(entry.GetComment()); m != nil {
+		return m[1]
+	}
+	return UntaggedGroup
+}
+
+// GroupByKeyPrefix returns a grouping by the first n characters of the
+// key.
+func GroupByKeyPrefix(n int) func(*UserDictionary_Entry) string {
+	return func(entry *UserDictionary_Entry) string {
+		key := []rune(entry.GetKey())
+		if len(key) > n {
+			key = key[:n]
+		}
+		return string(key)
+	}
+}
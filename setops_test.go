@@ -0,0 +1,95 @@
+package gimedic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func setDictionary(records ...Record) *UserDictionary {
+	dict := &UserDictionary{}
+	for _, record := range records {
+		dict.Entries = append(dict.Entries, record.Entry())
+	}
+	return dict
+}
+
+func entryKeys(entries []*UserDictionary_Entry) []string {
+	keys := []string{}
+	for _, entry := range entries {
+		keys = append(keys, entry.GetKey()+"="+entry.GetValue())
+	}
+	return keys
+}
+
+func TestSetOperations(t *testing.T) {
+	a := setDictionary(
+		Record{Key: "あ", Value: "亜", Comment: "from a"},
+		Record{Key: "い", Value: "井"},
+		Record{Key: "う", Value: "宇"},
+	)
+	b := setDictionary(
+		Record{Key: "あ", Value: "亜", Comment: "from b"},
+		Record{Key: "い", Value: "伊"},
+		Record{Key: "え", Value: "江"},
+	)
+	union := Union(a, b)
+	if got, want := entryKeys(union), []string{"あ=亜", "い=井", "う=宇", "い=伊", "え=江"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Union: got %v, want %v", got, want)
+	}
+	if union[0].GetComment() != "from a" {
+		t.Fatalf("Union took fields from the later dictionary: %v", union[0])
+	}
+	if got, want := entryKeys(Intersection(a, b)), []string{"あ=亜"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Intersection: got %v, want %v", got, want)
+	}
+	if got, want := entryKeys(Subtract(a, b)), []string{"い=井", "う=宇"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Subtract: got %v, want %v", got, want)
+	}
+	union[0].Comment = ptr("changed")
+	if a.GetEntries()[0].GetComment() != "from a" {
+		t.Fatal("Union shares entries with its input")
+	}
+}
+
+func TestSetOperationsHomographs(t *testing.T) {
+	a := setDictionary(
+		Record{Key: "あい", Value: "愛", Pos: PartNoun},
+		Record{Key: "あい", Value: "愛", Pos: PartSuruNoun},
+	)
+	b := setDictionary(
+		Record{Key: "あい", Value: "愛", Pos: PartNoun},
+	)
+	if got := Union(a, b); len(got) != 2 || Part(got[1].GetPos()) != PartSuruNoun {
+		t.Fatalf("Union dropped a homograph: %v", got)
+	}
+	if got := Intersection(a, b); len(got) != 1 || Part(got[0].GetPos()) != PartNoun {
+		t.Fatalf("Intersection: %v", got)
+	}
+	if got := Subtract(a, b); len(got) != 1 || Part(got[0].GetPos()) != PartSuruNoun {
+		t.Fatalf("Subtract: %v", got)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	dict := setDictionary(
+		Record{Key: "あい", Value: "愛", Pos: PartNoun, Comment: "#love words"},
+		Record{Key: "あお", Value: "青", Pos: PartAdjectivalNoun, Comment: "color #palette #x"},
+		Record{Key: "かお", Value: "顔", Pos: PartNoun, Comment: "no#tag"},
+	)
+	count := func(groups map[string][]*UserDictionary_Entry) map[string]int {
+		counts := map[string]int{}
+		for name, entries := range groups {
+			counts[name] = len(entries)
+		}
+		return counts
+	}
+	if got, want := count(Split(dict, GroupByPart)), map[string]int{"名詞": 2, "名詞形動": 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("by part: got %v, want %v", got, want)
+	}
+	if got, want := count(Split(dict, GroupByTag)), map[string]int{"love": 1, "palette": 1, UntaggedGroup: 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("by tag: got %v, want %v", got, want)
+	}
+	if got, want := count(Split(dict, GroupByKeyPrefix(1))), map[string]int{"あ": 2, "か": 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("by prefix: got %v, want %v", got, want)
+	}
+}
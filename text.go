@@ -0,0 +1,48 @@
+package gimedic
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Skipped describes an entry that could not be converted between formats,
+// typically because its POS has no equivalent on the other side.
+type Skipped struct {
+	// Line is the source line of the entry, or 0 when writing.
+	Line  int
+	Key   string
+	Value string
+	Pos   string
+	// Reason explains why the entry was skipped when it is not its POS.
+	Reason string
+}
+
+func (s Skipped) String() string {
+	reason := s.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("no equivalent for POS %q", s.Pos)
+	}
+	if s.Line > 0 {
+		return fmt.Sprintf("line %d: %s\t%s: %s", s.Line, s.Key, s.Value, reason)
+	}
+	return fmt.Sprintf("%s\t%s: %s", s.Key, s.Value, reason)
+}
+
+// singleLine replaces tabs and line breaks for formats without escaping.
+var singleLine = strings.NewReplacer("\t", " ", "\r\n", " ", "\r", " ", "\n", " ")
+
+// newTextReader decodes r as UTF-8 or UTF-16 when it starts with a BOM,
+// and with fallback otherwise. The BOM is removed.
+func newTextReader(r io.Reader, fallback encoding.Encoding) io.Reader {
+	return transform.NewReader(r, unicode.BOMOverride(fallback.NewDecoder()))
+}
+
+// newUTF16Writer encodes text written to w as UTF-16LE with a BOM.
+func newUTF16Writer(w io.Writer) io.WriteCloser {
+	return transform.NewWriter(w, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder())
+}
@@ -0,0 +1,104 @@
+package gimedic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// posTable maps the POS names of another IME onto Parts.
+// Several names may map onto one Part; the first one is written out.
+type posTable []struct {
+	name string
+	part Part
+}
+
+// part returns the Part for the name, falling back to the Mozc labels.
+func (t posTable) part(name string) (Part, bool) {
+	for _, p := range t {
+		if p.name == name {
+			return p.part, true
+		}
+	}
+	return ParsePart(name)
+}
+
+func (t posTable) name(part Part) (string, bool) {
+	for _, p := range t {
+		if p.part == part {
+			return p.name, true
+		}
+	}
+	return "", false
+}
+
+// readWordList reads "reading<TAB>word<TAB>POS[<TAB>comment]" lines as
+// used by the dictionary tools of Microsoft IME and ATOK. Blank lines and
+// lines starting with headerPrefix are ignored. Entries whose POS is not
+// in the table are returned as skipped.
+func readWordList(r io.Reader, headerPrefix string, table posTable) ([]*UserDictionary_Entry, []Skipped, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var entries []*UserDictionary_Entry
+	var skipped []Skipped
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, headerPrefix) {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, nil, fmt.Errorf("line %d: expected 3 or 4 tab-separated fields, got %d", line, len(fields))
+		}
+		if fields[0] == "" || fields[1] == "" {
+			return nil, nil, fmt.Errorf("line %d: empty reading or word", line)
+		}
+		part, ok := table.part(fields[2])
+		if !ok {
+			skipped = append(skipped, Skipped{Line: line, Key: fields[0], Value: fields[1], Pos: fields[2]})
+			continue
+		}
+		record := Record{Key: fields[0], Value: fields[1], Pos: part}
+		if len(fields) == 4 {
+			record.Comment = fields[3]
+		}
+		entries = append(entries, record.Entry())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return entries, skipped, nil
+}
+
+// writeWordList writes the header and entries in UTF-16LE with a BOM and
+// CRLF line endings. Entries whose Part is not in the table are left out
+// and returned as skipped.
+func writeWordList(w io.Writer, header string, entries []*UserDictionary_Entry, table posTable, withComment bool) ([]Skipped, error) {
+	encoder := newUTF16Writer(w)
+	writer := bufio.NewWriter(encoder)
+	fmt.Fprint(writer, header)
+	var skipped []Skipped
+	for _, entry := range entries {
+		part := Part(entry.GetPos())
+		name, ok := table.name(part)
+		if !ok {
+			skipped = append(skipped, Skipped{Key: entry.GetKey(), Value: entry.GetValue(), Pos: part.String()})
+			continue
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s", singleLine.Replace(entry.GetKey()), singleLine.Replace(entry.GetValue()), name)
+		if comment := entry.GetComment(); withComment && comment != "" {
+			fmt.Fprintf(writer, "\t%s", singleLine.Replace(comment))
+		}
+		fmt.Fprint(writer, "\r\n")
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return skipped, nil
+}